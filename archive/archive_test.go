@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveInScope(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "usr/lib"), 0755))
+	assert.Nil(t, os.Symlink("/usr/lib", filepath.Join(root, "lib")))
+	assert.Nil(t, os.Symlink("../../..", filepath.Join(root, "usr/lib/up")))
+	assert.Nil(t, os.Symlink("/private/etc", filepath.Join(root, "etc")))
+	assert.Nil(t, os.Symlink("loop", filepath.Join(root, "loop")))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"lib/libc.so", "/usr/lib/libc.so"},
+		{"/usr/lib/up/bin/sh", "/bin/sh"},
+		{"/../../usr", "/usr"},
+		{"/etc/passwd", "/private/etc/passwd"},
+		{"/lib/../bin", "/usr/bin"},
+	}
+	for _, test := range tests {
+		resolved, err := ResolveInScope(root, test.path)
+		assert.Nil(t, err, test.path)
+		assert.Equal(t, filepath.Join(root, test.want), resolved, test.path)
+	}
+
+	_, err := ResolveInScope(root, "/loop/file")
+	assert.NotNil(t, err)
+
+	resolved, err := ResolveParentInScope(root, "/lib")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "lib"), resolved)
+}
+
+func TestTarUntar(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "app/conf"), 0750))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "app/run.sh"), []byte("run"), 0755))
+	assert.Nil(t, os.Chmod(filepath.Join(src, "app/run.sh"), 0755|os.ModeSetuid))
+	assert.Nil(t, os.Link(filepath.Join(src, "app/run.sh"), filepath.Join(src, "app/conf/run.sh")))
+	assert.Nil(t, os.Symlink("../run.sh", filepath.Join(src, "app/conf/link")))
+
+	assert.Nil(t, Untar(Tar(filepath.Join(src, "app"), "copy"), dst, "/"))
+
+	info, err := os.Stat(filepath.Join(dst, "copy/conf"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(dst, "copy/run.sh"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0755|os.ModeSetuid, info.Mode())
+	assert.Equal(t, uint64(2), uint64(info.Sys().(*syscall.Stat_t).Nlink))
+	link, err := os.Readlink(filepath.Join(dst, "copy/conf/link"))
+	assert.Nil(t, err)
+	assert.Equal(t, "../run.sh", link)
+	content, err := os.ReadFile(filepath.Join(dst, "copy/conf/link"))
+	assert.Nil(t, err)
+	assert.Equal(t, "run", string(content))
+}
+
+func TestUntarInScope(t *testing.T) {
+	src, root, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "passwd"), []byte("root"), 0644))
+	// root 中指向绝对路径的链接应该在 root 中解析
+	assert.Nil(t, os.Symlink(outside, filepath.Join(root, "etc")))
+
+	assert.Nil(t, Untar(Tar(filepath.Join(src, "passwd"), "etc/passwd"), root, "/"))
+
+	_, err := os.Stat(filepath.Join(outside, "passwd"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(root, outside, "passwd"))
+	assert.Nil(t, err)
+}
+
+func TestTarExcludes(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "data/db"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "data/db/file"), []byte("data"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "file"), []byte("file"), 0644))
+
+	assert.Nil(t, Untar(Tar(src, ".", "data/"), dst, "/"))
+
+	entries, err := os.ReadDir(filepath.Join(dst, "data"))
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	_, err = os.Stat(filepath.Join(dst, "file"))
+	assert.Nil(t, err)
+}
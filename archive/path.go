@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks 解析一个路径时最多跟随的符号链接数量，和 linux 的 MAXSYMLINKS 一致
+const maxSymlinks = 40
+
+// ResolveInScope 把 root 中的路径 p 解析为宿主机上的路径
+/*
+1）p 中的符号链接都相对于 root 解析，绝对路径的链接从 root 开始，.. 最多回到 root，结果不会超出 root
+2）不存在的部分按字面拼接，用于解析还没有创建的目标路径
+3）容器中的文件不可信，不能直接用宿主机的路径解析，否则 /etc -> /host/etc 这样的链接会指向宿主机上的文件
+*/
+func ResolveInScope(root, p string) (string, error) {
+	root = filepath.Clean(root)
+	resolved := "/"
+	remaining := p
+	links := 0
+	for remaining != "" {
+		var part string
+		remaining = strings.TrimLeft(remaining, "/")
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i:]
+		} else {
+			part, remaining = remaining, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many links in %s", p)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// ResolveParentInScope 和 ResolveInScope 一样解析 p，但是不跟随最后一级的符号链接，用于复制符号链接本身
+func ResolveParentInScope(root, p string) (string, error) {
+	p = filepath.Clean("/" + p)
+	if p == "/" {
+		return filepath.Clean(root), nil
+	}
+	parent, err := ResolveInScope(root, filepath.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(p)), nil
+}
@@ -0,0 +1,317 @@
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// xattrPrefix tar 中用 PAX 记录保存 xattr 的前缀，和 GNU tar、docker 一致
+const xattrPrefix = "SCHILY.xattr."
+
+// fileID 用于识别硬链接，volume 挂载在 merged 目录中，inode 需要和设备号一起比较
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// Tar 把 src 打包为 tar 流，src 在包中的名称为 name，name 为 "." 时只打包 src 目录中的内容
+/*
+1）不跟随符号链接，src 本身为符号链接时打包的是链接本身
+2）保存数字形式的属主、权限、修改时间和 xattr，同一个 inode 的文件只保存一次，之后的写为硬链接
+3）打包在单独的 goroutine 中进行，出错时读取方会收到对应的错误
+4）excludes 为 src 中的相对路径，只打包目录本身，不打包其中的内容，用于跳过挂载在其中的 volume
+*/
+func Tar(src, name string, excludes ...string) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(writer, src, name, excludes))
+	}()
+	return reader
+}
+
+func writeTar(w io.Writer, src, name string, excludes []string) error {
+	tw := tar.NewWriter(w)
+	links := map[fileID]string{}
+	skipped := map[string]bool{}
+	for _, exclude := range excludes {
+		skipped[filepath.Clean(exclude)] = true
+	}
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		entry := filepath.Join(name, rel)
+		if entry != "." {
+			if err = writeEntry(tw, p, entry, info, links); err != nil {
+				return err
+			}
+		}
+		if info.IsDir() && skipped[rel] {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeEntry(tw *tar.Writer, p, name string, info os.FileInfo, links map[fileID]string) error {
+	// tar 不支持 socket
+	if info.Mode()&os.ModeSocket != 0 {
+		return nil
+	}
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("create tar header of %s error: %w", p, err)
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// 容器中的用户名和宿主机上的不一定对应，只保留数字形式的属主
+	hdr.Uname, hdr.Gname = "", ""
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && hdr.Typeflag == tar.TypeReg && stat.Nlink > 1 {
+		id := fileID{dev: uint64(stat.Dev), ino: stat.Ino}
+		if first, ok := links[id]; ok {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = first
+			hdr.Size = 0
+		} else {
+			links[id] = hdr.Name
+		}
+	}
+
+	// syscall 中没有 llistxattr，符号链接的 xattr 不做处理
+	if hdr.Typeflag != tar.TypeSymlink {
+		xattrs, err := readXattrs(p)
+		if err != nil {
+			return fmt.Errorf("read xattrs of %s error: %w", p, err)
+		}
+		for key, value := range xattrs {
+			if hdr.PAXRecords == nil {
+				hdr.PAXRecords = map[string]string{}
+			}
+			hdr.PAXRecords[xattrPrefix+key] = value
+		}
+	}
+
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func readXattrs(p string) (map[string]string, error) {
+	size, err := syscall.Listxattr(p, nil)
+	if errors.Is(err, syscall.ENOTSUP) || size == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(p, buf); err != nil {
+		return nil, err
+	}
+
+	// overlayfs 返回的大小包括隐藏的 trusted.overlay.* ，实际读到的可能为空
+	xattrs := map[string]string{}
+	for _, key := range strings.Split(string(buf[:size]), "\x00") {
+		if key == "" {
+			continue
+		}
+		valueSize, err := syscall.Getxattr(p, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, valueSize)
+		if valueSize, err = syscall.Getxattr(p, key, value); err != nil {
+			return nil, err
+		}
+		xattrs[key] = string(value[:valueSize])
+	}
+	return xattrs, nil
+}
+
+// Untar 把 tar 流解压到 root 中的目录 dest，dest 为 root 中的路径
+/*
+1）每一项的父目录都通过 ResolveInScope 在 root 中解析，不会通过已有的符号链接写到 root 之外
+2）已经存在的目录和包中的目录合并，已经存在的文件被覆盖，不能用文件覆盖已经存在的目录
+3）恢复属主、权限、修改时间和 xattr，目录的修改时间在其中的文件都解压之后再设置
+*/
+func Untar(r io.Reader, root, dest string) error {
+	tr := tar.NewReader(r)
+	var dirs []*tar.Header
+	var dirPaths []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tar error: %w", err)
+		}
+		// 只复制目录中的内容时包中有 "./" 这一项，对应 dest 本身，不做处理
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid tar entry %s", hdr.Name)
+		}
+		target, err := ResolveParentInScope(root, filepath.Join(dest, name))
+		if err != nil {
+			return err
+		}
+		// 包中可能没有父目录这一项
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err = extractEntry(tr, hdr, root, dest, target); err != nil {
+			return fmt.Errorf("extract %s error: %w", hdr.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+			dirPaths = append(dirPaths, target)
+		}
+	}
+
+	for i, hdr := range dirs {
+		if err := os.Chtimes(dirPaths[i], accessTime(hdr), hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractEntry(tr *tar.Reader, hdr *tar.Header, root, dest, target string) error {
+	if info, err := os.Lstat(target); err == nil {
+		if info.IsDir() {
+			if hdr.Typeflag != tar.TypeDir {
+				return fmt.Errorf("cannot overwrite directory %s with non-directory", target)
+			}
+		} else if err = os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.Mkdir(target, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+	case tar.TypeReg:
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		linkname := filepath.FromSlash(hdr.Linkname)
+		if !filepath.IsLocal(linkname) {
+			return fmt.Errorf("invalid hard link target %s", hdr.Linkname)
+		}
+		source, err := ResolveParentInScope(root, filepath.Join(dest, linkname))
+		if err != nil {
+			return err
+		}
+		// 硬链接和原文件共享属性，不需要再设置
+		return os.Link(source, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		mode := uint32(hdr.Mode & 07777)
+		switch hdr.Typeflag {
+		case tar.TypeChar:
+			mode |= syscall.S_IFCHR
+		case tar.TypeBlock:
+			mode |= syscall.S_IFBLK
+		default:
+			mode |= syscall.S_IFIFO
+		}
+		if err := syscall.Mknod(target, mode, mkdev(hdr.Devmajor, hdr.Devminor)); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	return setAttributes(target, hdr)
+}
+
+// setAttributes 先修改属主再修改权限，chown 会清除 setuid、setgid 位
+func setAttributes(target string, hdr *tar.Header) error {
+	if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+	for key, value := range hdr.PAXRecords {
+		name, ok := strings.CutPrefix(key, xattrPrefix)
+		if !ok {
+			continue
+		}
+		// 文件系统不支持或者没有权限设置的 xattr（例如非 root 用户设置 trusted.*）直接忽略
+		err := syscall.Setxattr(target, name, []byte(value), 0)
+		if err != nil && !errors.Is(err, syscall.ENOTSUP) && !errors.Is(err, syscall.EPERM) {
+			return fmt.Errorf("set xattr %s error: %w", name, err)
+		}
+	}
+	if err := os.Chmod(target, hdr.FileInfo().Mode()); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeDir {
+		return nil
+	}
+	return os.Chtimes(target, accessTime(hdr), hdr.ModTime)
+}
+
+func accessTime(hdr *tar.Header) time.Time {
+	if hdr.AccessTime.IsZero() {
+		return hdr.ModTime
+	}
+	return hdr.AccessTime
+}
+
+// mkdev 和 glibc 的 makedev 一致
+func mkdev(major, minor int64) int {
+	return int((minor & 0xff) | ((major & 0xfff) << 8) | ((minor &^ 0xff) << 12) | ((major &^ 0xfff) << 32))
+}
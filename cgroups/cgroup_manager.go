@@ -1,6 +1,10 @@
 package cgroups
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/sirupsen/logrus"
 	
 	"github.com/wangstu/mydocker/cgroups/subsystems"
@@ -44,3 +48,62 @@ func (c *CgroupManager) Destory() error {
 	}
 	return nil
 }
+
+// Update 修改运行中容器的资源限制，pids 为容器中的所有进程
+// 启动时没有配置的 subsystem（比如 cpuset）的 cgroup 中还没有进程，需要把容器中的进程都加进去，已经在其中的进程不受影响
+func (c *CgroupManager) Update(pids []int) error {
+	for _, subSysIns := range subsystems.SubsystemsIns {
+		if err := subSysIns.Set(c.Path, c.Resource); err != nil {
+			return fmt.Errorf("set subsystem %s error: %w", subSysIns.Name(), err)
+		}
+		for _, pid := range pids {
+			if err := subSysIns.Apply(c.Path, pid, c.Resource); err != nil {
+				return fmt.Errorf("apply subsystem %s error: %w", subSysIns.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// OOMKilled 判断 cgroup 中的进程是否被 OOM killer 杀死过
+func (c *CgroupManager) OOMKilled() bool {
+	memSubSys := &subsystems.MemorySubSystem{}
+	return memSubSys.OOMKilled(c.Path)
+}
+
+// Freeze 暂停 cgroup 中的所有进程
+func (c *CgroupManager) Freeze() error {
+	freezer := &subsystems.FreezerSubSystem{}
+	return freezer.Freeze(c.Path)
+}
+
+// GetStats 读取 cgroup 中的资源使用情况
+func (c *CgroupManager) GetStats() (*subsystems.Stats, error) {
+	stats := &subsystems.Stats{}
+	for _, subSysIns := range subsystems.SubsystemsIns {
+		reader, ok := subSysIns.(subsystems.StatsReader)
+		if !ok {
+			continue
+		}
+		if err := reader.GetStats(c.Path, stats); err != nil {
+			// 旧版本启动的容器没有加入只用来统计的 subsystem
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("get %s stats error: %w", subSysIns.Name(), err)
+		}
+	}
+	return stats, nil
+}
+
+// GetPids 返回 cgroup 中的所有进程
+func (c *CgroupManager) GetPids() ([]int, error) {
+	freezer := &subsystems.FreezerSubSystem{}
+	return freezer.GetPids(c.Path)
+}
+
+// Thaw 恢复 cgroup 中被暂停的进程
+func (c *CgroupManager) Thaw() error {
+	freezer := &subsystems.FreezerSubSystem{}
+	return freezer.Thaw(c.Path)
+}
@@ -0,0 +1,81 @@
+package subsystems
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+// BlkioSubSystem 统计块设备的读写字节数，总是创建 cgroup 并加入进程
+type BlkioSubSystem struct{}
+
+func (s *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+func (s *BlkioSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := getCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup proc error: %w", err)
+	}
+	return nil
+}
+
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// GetStats 汇总各个设备的读写字节数
+func (s *BlkioSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if findCgroupMountPoint(s.Name()) == "" {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path.Join(subsysCgroupPath, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return fmt.Errorf("read blkio stat error: %w", err)
+	}
+	defer f.Close()
+
+	// 8:0 Read 4096
+	// 8:0 Write 0
+	// Total 4096
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid blkio stat %s: %w", scanner.Text(), err)
+		}
+		switch fields[1] {
+		case "Read":
+			stats.Blkio.Read += value
+		case "Write":
+			stats.Blkio.Write += value
+		}
+	}
+	return scanner.Err()
+}
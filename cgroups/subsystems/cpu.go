@@ -9,6 +9,7 @@ import (
 	"github.com/wangstu/mydocker/constant"
 )
 
+// CpuSubSystem 没有 CPU 限制时同样创建 cgroup 并加入进程，用来统计 CPU 被限流的情况
 type CpuSubSystem struct{}
 
 const (
@@ -21,9 +22,6 @@ func (s *CpuSubSystem) Name() string{
 }
 
 func (s *CpuSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
-	if res.CpuCfsQuota == 0 && res.CpuShare == "" {
-		return nil
-	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return err
@@ -37,21 +35,21 @@ func (s *CpuSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	}
 
 	// cpu.cfs_period_us & cpu.cfs_quota_us 控制的是CPU使用时间，单位是微秒，比如每1秒钟，这个进程只能使用200ms，相当于只能用20%的CPU
-	if res.CpuCfsQuota != 0 {
+	// 没有限制时写入 -1，修改资源限制时可以去掉之前的限制
+	quota := "-1"
+	if res.CpuCfsQuota > 0 {
 		if err = os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_period_us"), []byte(strconv.Itoa(PeriodDefault)), constant.Perm0644); err != nil {
 			return fmt.Errorf("set cgroup share error: %w", err)
 		}
-		if err = os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.Itoa(PeriodDefault/Percent*res.CpuCfsQuota)), constant.Perm0644); err != nil {
-			return fmt.Errorf("set cgroup cpu share error: %w", err)
-		}
+		quota = strconv.Itoa(PeriodDefault / Percent * res.CpuCfsQuota)
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_quota_us"), []byte(quota), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup cpu share error: %w", err)
 	}
 	return nil
 }
 
 func (s *CpuSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
-	if res.CpuCfsQuota == 0 && res.CpuShare == "" {
-		return nil
-	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
 	if err != nil {
 		return err
@@ -68,4 +66,22 @@ func (s *CpuSubSystem) Remove(cgroupPath string) error {
 		return err
 	}
 	return os.RemoveAll(subsysCgroupPath)
-}
\ No newline at end of file
+}
+// GetStats 读取 cpu.stat 中的限流信息，CPU 时间由 cpuacct 统计
+func (s *CpuSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if findCgroupMountPoint(s.Name()) == "" {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	cpuStat, err := readKeyValues(subsysCgroupPath, "cpu.stat")
+	if err != nil {
+		return fmt.Errorf("read cpu stat error: %w", err)
+	}
+	stats.Cpu.Periods = cpuStat["nr_periods"]
+	stats.Cpu.ThrottledPeriods = cpuStat["nr_throttled"]
+	stats.Cpu.ThrottledTime = cpuStat["throttled_time"]
+	return nil
+}
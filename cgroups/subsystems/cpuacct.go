@@ -0,0 +1,55 @@
+package subsystems
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+// CpuacctSubSystem 只用来统计 CPU 使用时间，总是创建 cgroup 并加入进程
+type CpuacctSubSystem struct{}
+
+func (s *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
+
+func (s *CpuacctSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := getCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *CpuacctSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup proc error: %w", err)
+	}
+	return nil
+}
+
+func (s *CpuacctSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+func (s *CpuacctSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if findCgroupMountPoint(s.Name()) == "" {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Cpu.Usage, err = readUint(subsysCgroupPath, "cpuacct.usage"); err != nil {
+		return fmt.Errorf("read cpu usage error: %w", err)
+	}
+	return nil
+}
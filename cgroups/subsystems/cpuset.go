@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	
@@ -28,6 +30,20 @@ func (s *CpusetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	if err := os.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), constant.Perm0644); err != nil {
 		return fmt.Errorf("set cgroup cpuset error: %w", err)
 	}
+	// 新建的 cpuset cgroup 中 cpuset.mems 为空，此时无法加入进程，使用父 cgroup 的配置
+	mems, err := os.ReadFile(path.Join(subsysCgroupPath, "cpuset.mems"))
+	if err != nil {
+		return fmt.Errorf("read cgroup cpuset mems error: %w", err)
+	}
+	if strings.TrimSpace(string(mems)) != "" {
+		return nil
+	}
+	if mems, err = os.ReadFile(path.Join(path.Dir(subsysCgroupPath), "cpuset.mems")); err != nil {
+		return fmt.Errorf("read parent cgroup cpuset mems error: %w", err)
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "cpuset.mems"), mems, constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup cpuset mems error: %w", err)
+	}
 	return nil
 }
 
@@ -52,3 +68,56 @@ func (s *CpusetSubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsysCgroupPath)
 }
+
+// Validate 检查 cpus 的格式（比如 0-2,4），并且只能使用宿主机上可用的 CPU
+func (s *CpusetSubSystem) Validate(cpus string) error {
+	requested, err := parseCpuList(cpus)
+	if err != nil {
+		return err
+	}
+	root := findCgroupMountPoint(s.Name())
+	if root == "" {
+		return fmt.Errorf("cpuset cgroup is not mounted")
+	}
+	content, err := os.ReadFile(path.Join(root, "cpuset.cpus"))
+	if err != nil {
+		return fmt.Errorf("read available cpus error: %w", err)
+	}
+	available, err := parseCpuList(strings.TrimSpace(string(content)))
+	if err != nil {
+		return err
+	}
+	var unavailable []string
+	for cpu := range requested {
+		if !available[cpu] {
+			unavailable = append(unavailable, strconv.Itoa(cpu))
+		}
+	}
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		return fmt.Errorf("cpus %s are not available, available cpus: %s", strings.Join(unavailable, ","), strings.TrimSpace(string(content)))
+	}
+	return nil
+}
+
+// parseCpuList 解析 0-2,4 这样的 CPU 列表
+func parseCpuList(cpus string) (map[int]bool, error) {
+	result := map[int]bool{}
+	for _, part := range strings.Split(cpus, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpuset %s", cpus)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpuset %s", cpus)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			result[cpu] = true
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package subsystems
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+const (
+	frozenState = "FROZEN"
+	thawedState = "THAWED"
+
+	freezeRetryTimes    = 100
+	freezeRetryInterval = 10 * time.Millisecond
+)
+
+// FreezerSubSystem 通过 freezer 暂停和恢复 cgroup 中的所有进程
+// 和其他 Subsystem 不同，freezer 不需要资源配置，Set、Apply 总是会创建 cgroup 并加入进程，保证任何容器都可以被暂停
+// 没有 v1 freezer hierarchy 时使用 cgroup v2 的 cgroup.freeze
+type FreezerSubSystem struct{}
+
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+func (s *FreezerSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, _, err := s.getPath(cgroupPath, true)
+	return err
+}
+
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
+	subsysCgroupPath, v2, err := s.getPath(cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	procsFile := "tasks"
+	if v2 {
+		procsFile = "cgroup.procs"
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, procsFile), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup proc error: %w", err)
+	}
+	return nil
+}
+
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, _, err := s.getPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// Freeze 冻结 cgroup 中的所有进程，并等待冻结完成
+func (s *FreezerSubSystem) Freeze(cgroupPath string) error {
+	return s.setState(cgroupPath, frozenState)
+}
+
+// Thaw 恢复 cgroup 中被冻结的进程
+func (s *FreezerSubSystem) Thaw(cgroupPath string) error {
+	return s.setState(cgroupPath, thawedState)
+}
+
+// GetPids 返回 cgroup 中的所有进程
+// 每个容器都有 freezer cgroup，优先读取 cgroup.procs，只有 tasks 的老内核上 tasks 中是线程 id，由调用方去重
+func (s *FreezerSubSystem) GetPids(cgroupPath string) ([]int, error) {
+	subsysCgroupPath, _, err := s.getPath(cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path.Join(subsysCgroupPath, "cgroup.procs"))
+	if os.IsNotExist(err) {
+		content, err = os.ReadFile(path.Join(subsysCgroupPath, "tasks"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cgroup procs error: %w", err)
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %s in cgroup procs: %w", field, err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+func (s *FreezerSubSystem) setState(cgroupPath, state string) error {
+	subsysCgroupPath, v2, err := s.getPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+
+	// v1: freezer.state 写入 FROZEN/THAWED，冻结过程中读到的是 FREEZING
+	// v2: cgroup.freeze 写入 1/0，cgroup.events 中的 frozen 字段表示是否冻结完成
+	stateFile, value := "freezer.state", state
+	if v2 {
+		stateFile, value = "cgroup.freeze", "0"
+		if state == frozenState {
+			value = "1"
+		}
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, stateFile), []byte(value), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup freezer state error: %w", err)
+	}
+
+	for i := 0; i < freezeRetryTimes; i++ {
+		current, err := s.getState(subsysCgroupPath, v2)
+		if err != nil {
+			return err
+		}
+		if current == state {
+			return nil
+		}
+		time.Sleep(freezeRetryInterval)
+	}
+	return fmt.Errorf("wait cgroup %s to be %s timeout", cgroupPath, strings.ToLower(state))
+}
+
+func (s *FreezerSubSystem) getState(subsysCgroupPath string, v2 bool) (string, error) {
+	if !v2 {
+		content, err := os.ReadFile(path.Join(subsysCgroupPath, "freezer.state"))
+		if err != nil {
+			return "", fmt.Errorf("read cgroup freezer state error: %w", err)
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	content, err := os.ReadFile(path.Join(subsysCgroupPath, "cgroup.events"))
+	if err != nil {
+		return "", fmt.Errorf("read cgroup events error: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "frozen 1" {
+			return frozenState, nil
+		}
+	}
+	return thawedState, nil
+}
+
+// getPath 优先使用 v1 的 freezer hierarchy，返回值中的 bool 表示是否为 cgroup v2
+func (s *FreezerSubSystem) getPath(cgroupPath string, autoCreate bool) (string, bool, error) {
+	if findCgroupMountPoint(s.Name()) != "" {
+		subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, autoCreate)
+		return subsysCgroupPath, false, err
+	}
+
+	root := findCgroupV2MountPoint()
+	if root == "" {
+		return "", false, fmt.Errorf("neither freezer cgroup nor cgroup v2 is mounted")
+	}
+	absPath := path.Join(root, cgroupPath)
+	if autoCreate {
+		if err := os.MkdirAll(absPath, constant.Perm0755); err != nil {
+			return "", true, fmt.Errorf("create cgroup %s error: %w", absPath, err)
+		}
+	}
+	return absPath, true, nil
+}
@@ -5,12 +5,14 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	
 	"github.com/wangstu/mydocker/constant"
 )
 
+// MemorySubSystem 没有内存限制时同样创建 cgroup 并加入进程，用来统计内存使用情况
 type MemorySubSystem struct{}
 
 func (s *MemorySubSystem) Name() string {
@@ -18,13 +20,13 @@ func (s *MemorySubSystem) Name() string {
 }
 
 func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
-	if res.MemoryLimit == "" {
-		return nil
-	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return err
 	}
+	if res.MemoryLimit == "" {
+		return nil
+	}
 
 	// 设置这个cgroup的内存限制，即将限制写入到cgroup对应目录的memory.limit_in_bytes 文件中
 	if err = os.WriteFile(path.Join(subsysCgroupPath, "memory.limit_in_bytes"), []byte(res.MemoryLimit), constant.Perm0644); err != nil {
@@ -35,9 +37,6 @@ func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 
 // Apply 将pid加入到cgroupPath对应的cgroup中
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
-	if res.MemoryLimit == "" {
-		return nil
-	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
 	if err != nil {
 		return errors.Wrapf(err, "get cgroup %s", cgroupPath)
@@ -56,3 +55,53 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsysCgroupPath)
 }
+
+// OOMKilled 读取 memory.oom_control 中的 oom_kill 计数，判断 cgroup 中是否有进程因 OOM 被杀死
+func (s *MemorySubSystem) OOMKilled(cgroupPath string) bool {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return false
+	}
+	content, err := os.ReadFile(path.Join(subsysCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return false
+	}
+	// oom_kill_disable 0
+	// under_oom 0
+	// oom_kill 1
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, _ := strconv.Atoi(fields[1])
+			return count > 0
+		}
+	}
+	return false
+}
+
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if findCgroupMountPoint(s.Name()) == "" {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	usage, err := readUint(subsysCgroupPath, "memory.usage_in_bytes")
+	if err != nil {
+		return fmt.Errorf("read memory usage error: %w", err)
+	}
+	memStat, err := readKeyValues(subsysCgroupPath, "memory.stat")
+	if err != nil {
+		return fmt.Errorf("read memory stat error: %w", err)
+	}
+	if inactive := memStat["total_inactive_file"]; inactive < usage {
+		usage -= inactive
+	}
+	limit, err := readUint(subsysCgroupPath, "memory.limit_in_bytes")
+	if err != nil {
+		return fmt.Errorf("read memory limit error: %w", err)
+	}
+	stats.Memory = MemoryStats{Usage: usage, Limit: limit}
+	return nil
+}
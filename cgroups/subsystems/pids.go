@@ -0,0 +1,69 @@
+package subsystems
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+// PidsSubSystem 限制和统计 cgroup 中的进程数，总是创建 cgroup 并加入进程
+type PidsSubSystem struct{}
+
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+// Set 没有限制时写入 max，修改资源限制时可以去掉之前的限制
+func (s *PidsSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	limit := "max"
+	if res.PidsLimit > 0 {
+		limit = strconv.Itoa(res.PidsLimit)
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "pids.max"), []byte(limit), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup pids limit error: %w", err)
+	}
+	return nil
+}
+
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup proc error: %w", err)
+	}
+	return nil
+}
+
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if findCgroupMountPoint(s.Name()) == "" {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Pids.Current, err = readUint(subsysCgroupPath, "pids.current"); err != nil {
+		return fmt.Errorf("read pids current error: %w", err)
+	}
+	if stats.Pids.Limit, err = readUint(subsysCgroupPath, "pids.max"); err != nil {
+		return fmt.Errorf("read pids max error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package subsystems
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Stats cgroup 中的资源使用情况，各个字段由实现了 StatsReader 的 Subsystem 填充
+type Stats struct {
+	Memory MemoryStats
+	Cpu    CpuStats
+	Pids   PidsStats
+	Blkio  BlkioStats
+}
+
+type MemoryStats struct {
+	// Usage 不包括可以被回收的 inactive file cache，和 docker 一致
+	Usage uint64
+	Limit uint64
+}
+
+type CpuStats struct {
+	// Usage 消耗的 CPU 时间，单位为纳秒
+	Usage            uint64
+	Periods          uint64
+	ThrottledPeriods uint64
+	// ThrottledTime 被限流的时间，单位为纳秒
+	ThrottledTime uint64
+}
+
+type PidsStats struct {
+	Current uint64
+	// Limit 为 0 表示不限制
+	Limit uint64
+}
+
+type BlkioStats struct {
+	Read  uint64
+	Write uint64
+}
+
+// StatsReader 可以读取资源使用情况的 Subsystem，没有挂载对应的 hierarchy 时不做处理
+type StatsReader interface {
+	GetStats(cgroupPath string, stats *Stats) error
+}
+
+// readUint 读取只有一个整数的 cgroup 文件，max 表示不限制，返回 0
+func readUint(dir, file string) (uint64, error) {
+	content, err := os.ReadFile(path.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	result, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s in %s: %w", value, file, err)
+	}
+	return result, nil
+}
+
+// readKeyValues 读取 memory.stat、cpu.stat 这样每行为 "key value" 的文件
+func readKeyValues(dir, file string) (map[string]uint64, error) {
+	f, err := os.Open(path.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s in %s: %w", fields[1], file, err)
+		}
+		result[fields[0]] = value
+	}
+	return result, scanner.Err()
+}
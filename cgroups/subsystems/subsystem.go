@@ -1,16 +1,18 @@
 package subsystems
 
-// ResourceConfig 用于传递资源限制配置的结构体，包含内存限制，CPU 时间片权重，CPU核心数
+// ResourceConfig 用于传递资源限制配置的结构体，包含内存限制，CPU 时间片权重，CPU核心数，进程数限制
 type ResourceConfig struct {
 	MemoryLimit string
 	CpuCfsQuota int
 	CpuShare    string
 	CpuSet      string
+	// PidsLimit 为 0 表示不限制
+	PidsLimit int
 }
 
 // Subsystem 接口，每个Subsystem可以实现下面的4个接口，
 // 这里将cgroup抽象成了path,原因是cgroup在hierarchy的路径，便是虚拟文件系统中的虚拟路径
-// Set、Apply、Remove 这3个接口都判断一下，如果没有传配置信息进来就不处理，直接返回。
+// cpuset 没有传配置信息进来时不处理，直接返回；其他 Subsystem 总是创建 cgroup 并加入进程，用来统计资源使用情况
 type Subsystem interface {
 	// Name 返回当前Subsystem的名称,比如cpu、memory
 	Name() string
@@ -26,4 +28,8 @@ var SubsystemsIns = []Subsystem{
 	&CpusetSubSystem{},
 	&MemorySubSystem{},
 	&CpuSubSystem{},
+	&FreezerSubSystem{},
+	&CpuacctSubSystem{},
+	&PidsSubSystem{},
+	&BlkioSubSystem{},
 }
@@ -65,3 +65,24 @@ func findCgroupMountPoint(subsystem string) string {
 	}
 	return ""
 }
+
+// findCgroupV2MountPoint 找出 cgroup v2 unified hierarchy 的挂载点，没有挂载时返回空字符串
+func findCgroupV2MountPoint() string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 35 25 0:30 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate
+		fields := strings.Split(scanner.Text(), " ")
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" {
+				return fields[mountPointIndex]
+			}
+		}
+	}
+	return ""
+}
@@ -7,3 +7,23 @@ func TestFindCgroupMountpoint(t *testing.T) {
 	t.Logf("cpuset subsystem mount point: %v\n", findCgroupMountPoint("cpuset"))
 	t.Logf("memory subsystem mount point: %v\n", findCgroupMountPoint("memory"))
 }
+
+func TestParseCpuList(t *testing.T) {
+	cpus, err := parseCpuList("0-2,4")
+	if err != nil {
+		t.Fatalf("parse cpu list error: %v", err)
+	}
+	for _, cpu := range []int{0, 1, 2, 4} {
+		if !cpus[cpu] {
+			t.Errorf("cpu %d should be in the list", cpu)
+		}
+	}
+	if len(cpus) != 4 {
+		t.Errorf("expected 4 cpus, got %d", len(cpus))
+	}
+	for _, invalid := range []string{"", "a", "2-1", "1,", "-1"} {
+		if _, err = parseCpuList(invalid); err == nil {
+			t.Errorf("parse %q should fail", invalid)
+		}
+	}
+}
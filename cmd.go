@@ -11,6 +11,7 @@ import (
 	"github.com/wangstu/mydocker/cmds"
 	"github.com/wangstu/mydocker/container"
 	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
 )
 
 var runCmd = cli.Command{
@@ -38,6 +39,10 @@ var runCmd = cli.Command{
 			Name:  "cpuset",
 			Usage: "cpuset limit. eg: --cpuset 2,4",
 		},
+		cli.IntFlag{
+			Name:  "pids-limit",
+			Usage: "limit the number of processes. eg: --pids-limit 100",
+		},
 		cli.StringFlag{
 			Name:  "v",
 			Usage: "volume. eg: -v /etc/conf:/etc/conf",
@@ -56,8 +61,50 @@ var runCmd = cli.Command{
 		},
 		cli.StringSliceFlag{
 			Name: "p",
-			Usage: "port mapping. eg: -p 8080:80, -p 30336:3306",
+			Usage: "port mapping, [hostIP:][hostPort:]containerPort[/protocol], a free host port is allocated if omitted. eg: -p 8080:80, -p 127.0.0.1::53/udp",
+		},
+		cli.StringFlag{
+			Name:  "restart",
+			Usage: "restart policy of detached container, no|always|on-failure[:N]|unless-stopped. eg: --restart on-failure:3",
+		},
+		cli.StringFlag{
+			Name:  "stop-signal",
+			Usage: "signal to stop the container. eg: --stop-signal SIGINT",
+			Value: "SIGTERM",
+		},
+		cli.BoolFlag{
+			Name:  "rm",
+			Usage: "automatically remove the container when it exits",
+		},
+		cli.StringFlag{
+			Name:  "health-cmd",
+			Usage: "command to run in the container to check health. eg: --health-cmd 'cat /tmp/ready'",
+		},
+		cli.DurationFlag{
+			Name:  "health-interval",
+			Usage: "time between running the check",
+			Value: container.DefaultHealthInterval,
+		},
+		cli.DurationFlag{
+			Name:  "health-timeout",
+			Usage: "maximum time to allow one check to run",
+			Value: container.DefaultHealthTimeout,
+		},
+		cli.IntFlag{
+			Name:  "health-retries",
+			Usage: "consecutive failures needed to report unhealthy",
+			Value: container.DefaultHealthRetries,
+		},
+		cli.BoolFlag{
+			Name:  "health-restart",
+			Usage: "kill and relaunch the container when it becomes unhealthy, only for detached container",
+		},
+		cli.StringSliceFlag{
+			Name:  "hook",
+			Usage: "lifecycle hook, stage is one of createRuntime, prestart, poststart, poststop. eg: --hook prestart=/usr/bin/setup,arg1",
 		},
+		labelFlag,
+		labelFileFlag,
 	},
 
 	/*
@@ -76,21 +123,133 @@ var runCmd = cli.Command{
 			return fmt.Errorf("it and d paramater can not both provided")
 		}
 
+		restartPolicy, err := container.ParseRestartPolicy(ctx.String("restart"))
+		if err != nil {
+			return err
+		}
+		if tty && !restartPolicy.IsNone() {
+			return fmt.Errorf("restart policy can only be used with detached container")
+		}
+		autoRemove := ctx.Bool("rm")
+		if autoRemove && !restartPolicy.IsNone() {
+			return fmt.Errorf("conflicting options: --restart and --rm")
+		}
+
+		healthConfig, err := parseHealthConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if tty && healthConfig != nil && healthConfig.RestartOnUnhealthy {
+			return fmt.Errorf("health-restart can only be used with detached container")
+		}
+
+		stopSignal := ctx.String("stop-signal")
+		if _, err = utils.ParseSignal(stopSignal); err != nil {
+			return err
+		}
+
+		hooks, err := parseHooks(ctx.StringSlice("hook"))
+		if err != nil {
+			return err
+		}
+
+		labels, err := parseLabels(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, pm := range ctx.StringSlice("p") {
+			if _, err = container.ParsePortMapping(pm); err != nil {
+				return err
+			}
+		}
+
 		resourceConf := &subsystems.ResourceConfig{
 			MemoryLimit: ctx.String("mem"),
 			CpuSet:      ctx.String("cpuset"),
 			CpuCfsQuota: ctx.Int("cpu"),
+			PidsLimit:   ctx.Int("pids-limit"),
 		}
-		volume := ctx.String("v")
-		containerName := ctx.String("name")
-		envSlice := ctx.StringSlice("e")
-		networkName := ctx.String("net")
-		portMapping := ctx.StringSlice("p")
-		cmds.Run(tty, ctx.Args().Tail(), envSlice, resourceConf, volume, containerName, ctx.Args().First(), networkName, portMapping)
+		cmds.Run(cmds.RunOptions{
+			Tty:           tty,
+			Cmds:          ctx.Args().Tail(),
+			Env:           ctx.StringSlice("e"),
+			Resource:      resourceConf,
+			Volume:        ctx.String("v"),
+			Name:          ctx.String("name"),
+			Image:         ctx.Args().First(),
+			Network:       ctx.String("net"),
+			PortMapping:   ctx.StringSlice("p"),
+			RestartPolicy: restartPolicy,
+			StopSignal:    stopSignal,
+			AutoRemove:    autoRemove,
+			HealthConfig:  healthConfig,
+			Hooks:         hooks,
+			Labels:        labels,
+		})
 		return nil
 	},
 }
 
+// parseHealthConfig 没有指定 --health-cmd 时不做健康检查
+func parseHealthConfig(ctx *cli.Context) (*container.HealthConfig, error) {
+	healthCmd := ctx.String("health-cmd")
+	if healthCmd == "" {
+		if ctx.Bool("health-restart") {
+			return nil, fmt.Errorf("health-restart requires health-cmd")
+		}
+		return nil, nil
+	}
+	config := &container.HealthConfig{
+		Cmd:                healthCmd,
+		Interval:           ctx.Duration("health-interval"),
+		Timeout:            ctx.Duration("health-timeout"),
+		Retries:            ctx.Int("health-retries"),
+		RestartOnUnhealthy: ctx.Bool("health-restart"),
+	}
+	if config.Interval <= 0 || config.Timeout <= 0 {
+		return nil, fmt.Errorf("health-interval and health-timeout must be positive")
+	}
+	if config.Retries < 1 {
+		return nil, fmt.Errorf("health-retries must be at least 1")
+	}
+	return config, nil
+}
+
+var (
+	labelFlag = cli.StringSliceFlag{
+		Name:  "l, label",
+		Usage: "set metadata. eg: --label owner=alice",
+	}
+	labelFileFlag = cli.StringSliceFlag{
+		Name:  "label-file",
+		Usage: "read in a line delimited file of labels",
+	}
+)
+
+// parseLabels 解析 --label 和 --label-file 参数
+func parseLabels(ctx *cli.Context) (map[string]string, error) {
+	return utils.ParseLabels(ctx.StringSlice("label"), ctx.StringSlice("label-file"))
+}
+
+// parseHooks 解析 --hook 参数，没有指定时返回 nil
+func parseHooks(values []string) (*container.Hooks, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	hooks := &container.Hooks{}
+	for _, value := range values {
+		stage, hook, err := container.ParseHook(value)
+		if err != nil {
+			return nil, err
+		}
+		if err = hooks.Add(stage, hook); err != nil {
+			return nil, err
+		}
+	}
+	return hooks, nil
+}
+
 var initCmd = cli.Command{
 	Name:  "init",
 	Usage: "Init container process run user's process in container. Do not call it outside.",
@@ -103,25 +262,94 @@ var initCmd = cli.Command{
 	},
 }
 
+var monitorCmd = cli.Command{
+	Name:  "monitor",
+	Usage: "Monitor process of detached container. Do not call it outside.",
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container id")
+		}
+		return cmds.MonitorContainer(ctx.Args().Get(0))
+	},
+}
+
 var commitCmd = cli.Command{
 	Name:  "commit",
 	Usage: "commit container to image. eg: mydocker commit iwue8390he myimage",
+	Flags: []cli.Flag{
+		cli.BoolTFlag{
+			Name:  "pause",
+			Usage: "pause container during commit, default true. eg: --pause=false",
+		},
+		labelFlag,
+		labelFileFlag,
+	},
 	Action: func(ctx *cli.Context) error {
 		if len(ctx.Args()) < 2 {
 			return fmt.Errorf("missing container id and image name")
 		}
-		containerId := ctx.Args().Get(0)
+		containerId, err := cmds.ResolveContainerId(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		imageName := ctx.Args().Get(1)
-		return cmds.Commit(containerId, imageName)
+		labels, err := parseLabels(ctx)
+		if err != nil {
+			return err
+		}
+		return cmds.Commit(containerId, imageName, ctx.BoolT("pause"), labels)
+	},
+}
+
+var pauseCmd = cli.Command{
+	Name:  "pause",
+	Usage: "pause all processes within containers. eg: mydocker pause iwue8390he",
+	Action: func(ctx *cli.Context) error {
+		return forEachContainer(ctx, cmds.PauseContainer)
+	},
+}
+
+var unpauseCmd = cli.Command{
+	Name:  "unpause",
+	Usage: "unpause all processes within containers. eg: mydocker unpause iwue8390he",
+	Action: func(ctx *cli.Context) error {
+		return forEachContainer(ctx, cmds.UnpauseContainer)
 	},
 }
 
 var listCmd = cli.Command{
 	Name:  "ps",
-	Usage: "list containers",
+	Usage: "list containers. eg: mydocker ps -a --filter status=exited",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "a, all",
+			Usage: "show all containers, only running containers are shown by default",
+		},
+		cli.BoolFlag{
+			Name:  "q, quiet",
+			Usage: "only display container ids",
+		},
+		cli.BoolFlag{
+			Name:  "no-trunc",
+			Usage: "do not truncate output",
+		},
+		cli.StringSliceFlag{
+			Name:  "f, filter",
+			Usage: "filter output, supports id, name, status, network, health and label. eg: --filter status=exited,name=web",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, table|json|Go template. eg: --format '{{.ID}} {{.Status}}'",
+		},
+	},
 	Action: func(ctx *cli.Context) error {
-		cmds.ListContainers()
-		return nil
+		return cmds.ListContainers(cmds.ListOptions{
+			All:     ctx.Bool("a"),
+			Quiet:   ctx.Bool("q"),
+			NoTrunc: ctx.Bool("no-trunc"),
+			Filters: ctx.StringSlice("f"),
+			Format:  ctx.String("format"),
+		})
 	},
 }
 
@@ -132,7 +360,10 @@ var logCmd = cli.Command{
 		if len(ctx.Args()) < 1 {
 			return fmt.Errorf("please input container id")
 		}
-		containerId := ctx.Args().Get(0)
+		containerId, err := cmds.ResolveContainerId(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		cmds.GetContainerLog(containerId)
 		return nil
 	},
@@ -149,28 +380,320 @@ var execCmd = cli.Command{
 		if len(ctx.Args()) < 2 {
 			return fmt.Errorf("missing container name or command")
 		}
-		containerId := ctx.Args().Get(0)
+		containerId, err := cmds.ResolveContainerId(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		commands := ctx.Args().Tail()
 		cmds.ExecContainer(containerId, commands)
 		return nil
 	},
 }
 
+var topCmd = cli.Command{
+	Name:  "top",
+	Usage: "display the running processes of a container. eg: mydocker top iwue8390he [ps options]",
+	// 容器之后的参数全部交给 ps
+	SkipFlagParsing: true,
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container id")
+		}
+		containerId, err := cmds.ResolveContainerId(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		return cmds.Top(containerId, ctx.Args().Tail())
+	},
+}
+
+var statsCmd = cli.Command{
+	Name:  "stats",
+	Usage: "display a live stream of containers resource usage. eg: mydocker stats --no-stream --format json iwue8390he",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "disable streaming stats and only pull the first result",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, table|json|Go template. eg: --format '{{.Name}} {{.MemUsage}}'",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		containerIds := make([]string, 0, len(ctx.Args()))
+		for _, ref := range ctx.Args() {
+			containerId, err := cmds.ResolveContainerId(ref)
+			if err != nil {
+				return err
+			}
+			containerIds = append(containerIds, containerId)
+		}
+		return cmds.Stats(cmds.StatsOptions{
+			Containers: containerIds,
+			NoStream:   ctx.Bool("no-stream"),
+			Format:     ctx.String("format"),
+		})
+	},
+}
+
+var updateCmd = cli.Command{
+	Name:  "update",
+	Usage: "update resource limits of containers. eg: mydocker update --mem 200m --pids-limit 100 iwue8390he",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "mem",
+			Usage: "memory limit. eg: --mem 200m",
+		},
+		cli.IntFlag{
+			Name:  "cpu",
+			Usage: "cpu quota, -1 for unlimited. eg: --cpu 50",
+		},
+		cli.StringFlag{
+			Name:  "cpuset",
+			Usage: "cpuset limit. eg: --cpuset 0-2",
+		},
+		cli.IntFlag{
+			Name:  "pids-limit",
+			Usage: "limit the number of processes, -1 for unlimited. eg: --pids-limit 100",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		opts := cmds.UpdateOptions{
+			Memory:      ctx.String("mem"),
+			CpuCfsQuota: ctx.Int("cpu"),
+			CpuSet:      ctx.String("cpuset"),
+			PidsLimit:   ctx.Int("pids-limit"),
+		}
+		return forEachContainer(ctx, func(containerId string) error {
+			return cmds.UpdateContainer(containerId, opts)
+		})
+	},
+}
+
+var diffCmd = cli.Command{
+	Name:  "diff",
+	Usage: "inspect changes to files or directories on a container's filesystem. eg: mydocker diff iwue8390he",
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container id")
+		}
+		containerId, err := cmds.ResolveContainerId(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		return cmds.Diff(containerId)
+	},
+}
+
+var cpCmd = cli.Command{
+	Name: "cp",
+	Usage: "copy files between a container and the host, use - to stream a tar archive from stdin or to stdout. " +
+		"eg: mydocker cp iwue8390he:/etc/hosts ./hosts, mydocker cp ./conf iwue8390he:/etc/app",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "L, follow-link",
+			Usage: "always follow symbol link in source path",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) != 2 {
+			return fmt.Errorf("usage: mydocker cp CONTAINER:SRC_PATH DEST_PATH|- or mydocker cp SRC_PATH|- CONTAINER:DEST_PATH")
+		}
+		return cmds.Copy(ctx.Args().Get(0), ctx.Args().Get(1), ctx.Bool("follow-link"))
+	},
+}
+
+var exportCmd = cli.Command{
+	Name:  "export",
+	Usage: "export a container's filesystem as a tar archive. eg: mydocker export -o rootfs.tar iwue8390he",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "o, output",
+			Usage: "write to a file instead of stdout",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container id")
+		}
+		containerId, err := cmds.ResolveContainerId(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		return cmds.Export(containerId, ctx.String("output"))
+	},
+}
+
+var importCmd = cli.Command{
+	Name:  "import",
+	Usage: "import the contents from a tarball to create an image, use - to read from stdin. eg: mydocker import -c 'CMD top' rootfs.tar myimage",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "c, change",
+			Usage: "apply Dockerfile instruction to the created image, support CMD, ENV and LABEL. eg: -c 'ENV PATH=/bin'",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 2 {
+			return fmt.Errorf("missing tarball and image name")
+		}
+		return cmds.Import(ctx.Args().Get(0), ctx.Args().Get(1), ctx.StringSlice("change"))
+	},
+}
+
+var portCmd = cli.Command{
+	Name:  "port",
+	Usage: "list port mappings or a specific mapping for the container. eg: mydocker port iwue8390he 80/tcp",
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container id")
+		}
+		containerId, err := cmds.ResolveContainerId(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		return cmds.Port(containerId, ctx.Args().Get(1))
+	},
+}
+
 var stopCmd = cli.Command{
 	Name:  "stop",
-	Usage: "stop container",
+	Usage: "stop containers. eg: mydocker stop -t 10 iwue8390he",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t, time",
+			Usage: "seconds to wait for stop before killing the container",
+			Value: cmds.DefaultStopTimeout,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		timeout := ctx.Int("t")
+		return forEachContainer(ctx, func(containerId string) error {
+			return cmds.StopContainer(containerId, timeout)
+		})
+	},
+}
+
+var killCmd = cli.Command{
+	Name:  "kill",
+	Usage: "send a signal to containers. eg: mydocker kill -s SIGHUP iwue8390he",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "s, signal",
+			Usage: "signal name or number",
+			Value: "SIGKILL",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		signal, err := utils.ParseSignal(ctx.String("s"))
+		if err != nil {
+			return err
+		}
+		return forEachContainer(ctx, func(containerId string) error {
+			return cmds.KillContainer(containerId, signal)
+		})
+	},
+}
+
+var startCmd = cli.Command{
+	Name:  "start",
+	Usage: "start stopped containers. eg: mydocker start iwue8390he",
+	Action: func(ctx *cli.Context) error {
+		return forEachContainer(ctx, cmds.StartContainer)
+	},
+}
+
+var restartCmd = cli.Command{
+	Name:  "restart",
+	Usage: "restart containers. eg: mydocker restart -t 10 iwue8390he",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t, time",
+			Usage: "seconds to wait for stop before killing the container",
+			Value: cmds.DefaultStopTimeout,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		timeout := ctx.Int("t")
+		return forEachContainer(ctx, func(containerId string) error {
+			return cmds.RestartContainer(containerId, timeout)
+		})
+	},
+}
+
+var waitCmd = cli.Command{
+	Name: "wait",
+	Usage: `block until containers stop, then print their exit codes.
+			the command exits with the first non-zero exit code. eg: mydocker wait iwue8390he`,
 	Action: func(ctx *cli.Context) error {
 		if len(ctx.Args()) < 1 {
 			return fmt.Errorf("missing container id")
 		}
-		cmds.StopContainer(ctx.Args().Get(0))
+		exitCode := 0
+		for _, ref := range ctx.Args() {
+			containerId, err := cmds.ResolveContainerId(ref)
+			code := 0
+			if err == nil {
+				code, err = cmds.WaitContainer(containerId)
+			}
+			if err != nil {
+				logrus.Errorf("wait container %s error: %v", ref, err)
+				code = 1
+			} else {
+				fmt.Println(code)
+			}
+			if exitCode == 0 {
+				exitCode = code
+			}
+		}
+		if exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
 		return nil
 	},
 }
 
+var inspectCmd = cli.Command{
+	Name: "inspect",
+	Usage: `display detailed information of containers, networks or images.
+			eg: mydocker inspect -f '{{.IP}}' iwue8390he`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "f, format",
+			Usage: "format the output using the given Go template",
+		},
+		cli.StringFlag{
+			Name:  "type",
+			Usage: "return information of specified type, container|network|image",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container, network or image name")
+		}
+		return cmds.Inspect(ctx.Args(), ctx.String("type"), ctx.String("f"))
+	},
+}
+
+var renameCmd = cli.Command{
+	Name:  "rename",
+	Usage: "rename a container. eg: mydocker rename iwue8390he ticket-1234",
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) != 2 {
+			return fmt.Errorf("usage: mydocker rename CONTAINER NEW_NAME")
+		}
+		containerId, err := cmds.ResolveContainerId(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return cmds.RenameContainer(containerId, ctx.Args().Get(1))
+	},
+}
+
 var rmCmd = cli.Command{
 	Name:  "rm",
-	Usage: "remove unused container",
+	Usage: "remove unused containers",
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "f",
@@ -178,13 +701,10 @@ var rmCmd = cli.Command{
 		},
 	},
 	Action: func(ctx *cli.Context) error {
-		if len(ctx.Args()) < 1 {
-			return fmt.Errorf("missing container id")
-		}
-		containerId := ctx.Args().Get(0)
 		force := ctx.Bool("f")
-		cmds.RemoveContainer(containerId, force)
-		return nil
+		return forEachContainer(ctx, func(containerId string) error {
+			return cmds.RemoveContainer(containerId, force)
+		})
 	},
 }
 
@@ -204,6 +724,8 @@ var networkCmd = cli.Command{
 					Name:  "subnet",
 					Usage: "subnet cidr block",
 				},
+				labelFlag,
+				labelFileFlag,
 			},
 			Action: func(ctx *cli.Context) error {
 				if len(ctx.Args()) < 1 {
@@ -212,8 +734,12 @@ var networkCmd = cli.Command{
 				driver := ctx.String("driver")
 				subnet := ctx.String("subnet")
 				name := ctx.Args().First()
+				labels, err := parseLabels(ctx)
+				if err != nil {
+					return err
+				}
 
-				if err := network.CreateNetwork(driver, subnet, name); err != nil {
+				if err = network.CreateNetwork(driver, subnet, name, labels); err != nil {
 					return fmt.Errorf("create network error: %w", err)
 				}
 				return nil
@@ -222,9 +748,14 @@ var networkCmd = cli.Command{
 		{
 			Name:  "list",
 			Usage: "list container network",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "f, filter",
+					Usage: "filter output, supports name, driver and label. eg: --filter label=owner=alice",
+				},
+			},
 			Action: func(ctx *cli.Context) error {
-				network.ListNetwork()
-				return nil
+				return cmds.ListNetworks(ctx.StringSlice("f"))
 			},
 		},
 		{
@@ -240,5 +771,110 @@ var networkCmd = cli.Command{
 				return nil
 			},
 		},
+		pruneCommand("remove all networks not used by any container", cmds.PruneNetworks),
 	},
 }
+
+var eventsCmd = cli.Command{
+	Name: "events",
+	Usage: `get real time events, without --until it keeps waiting for new events.
+			eg: mydocker events --since 1h --filter event=die --format json`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show events created since timestamp or relative time. eg: --since 10m",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "stream events until this timestamp or relative time",
+		},
+		cli.StringSliceFlag{
+			Name:  "f, filter",
+			Usage: "filter output based on conditions provided, type|event|container|network|label. eg: --filter event=die",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "format the output using json or a go template",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		return cmds.Events(cmds.EventsOptions{
+			Since:   ctx.String("since"),
+			Until:   ctx.String("until"),
+			Filters: ctx.StringSlice("filter"),
+			Format:  ctx.String("format"),
+		})
+	},
+}
+
+var pruneFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "filter",
+		Usage: "provide filter values (e.g. 'until=24h', 'label=owner=alice')",
+	},
+	cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "only show what would be removed",
+	},
+}
+
+// pruneCommand 生成各个 prune 子命令
+func pruneCommand(usage string, prune func(opts cmds.PruneOptions) error) cli.Command {
+	return cli.Command{
+		Name:  "prune",
+		Usage: usage,
+		Flags: pruneFlags,
+		Action: func(ctx *cli.Context) error {
+			return prune(cmds.PruneOptions{
+				Filters: ctx.StringSlice("filter"),
+				DryRun:  ctx.Bool("dry-run"),
+			})
+		},
+	}
+}
+
+var containerCmd = cli.Command{
+	Name:  "container",
+	Usage: "manage containers",
+	Subcommands: []cli.Command{
+		pruneCommand("remove all exited containers", cmds.PruneContainers),
+	},
+}
+
+var imageCmd = cli.Command{
+	Name:  "image",
+	Usage: "manage images",
+	Subcommands: []cli.Command{
+		pruneCommand("remove images not used by any container", cmds.PruneImages),
+	},
+}
+
+var systemCmd = cli.Command{
+	Name:  "system",
+	Usage: "manage mydocker",
+	Subcommands: []cli.Command{
+		pruneCommand("remove exited containers, unused networks and images, and orphaned layers", cmds.SystemPrune),
+	},
+}
+
+// forEachContainer 依次解析每个参数对应的容器（ID、ID 前缀或名称）并执行 fn，某个容器出错时继续处理剩下的容器
+func forEachContainer(ctx *cli.Context, fn func(containerId string) error) error {
+	if len(ctx.Args()) < 1 {
+		return fmt.Errorf("missing container id")
+	}
+	failed := 0
+	for _, ref := range ctx.Args() {
+		containerId, err := cmds.ResolveContainerId(ref)
+		if err == nil {
+			err = fn(containerId)
+		}
+		if err != nil {
+			logrus.Errorf("%s: %v", ref, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d containers failed", failed, len(ctx.Args()))
+	}
+	return nil
+}
@@ -5,10 +5,29 @@ import (
 	"os/exec"
 
 	"github.com/sirupsen/logrus"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/image"
 	"github.com/wangstu/mydocker/utils"
 )
 
-func Commit(containerId, imageName string) error {
+// Commit 将容器的 merged 目录打包为镜像，pause 为 true 时先暂停运行中的容器，保证得到一致的快照
+// 镜像继承容器的 label，labels 中同名的 label 会覆盖容器的 label
+func Commit(containerId, imageName string, pause bool, labels map[string]string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if pause && containerInfo.Status == container.RUNNING {
+		if err = PauseContainer(containerId); err != nil {
+			return err
+		}
+		defer func() {
+			if err := UnpauseContainer(containerId); err != nil {
+				logrus.Errorf("unpause container %s error: %v", containerId, err)
+			}
+		}()
+	}
+
 	mntPath := utils.GetMergedPath(containerId)
 	tarImagePath := utils.GetImagePath(imageName)
 	exist, err := utils.IsPathExist(tarImagePath)
@@ -22,6 +41,16 @@ func Commit(containerId, imageName string) error {
 	logrus.Infof("image tar path: %s", tarImagePath)
 	if _, err := exec.Command("tar", "-czf", tarImagePath, "-C", mntPath, ".").CombinedOutput(); err != nil {
 		logrus.Errorf("save conatainer image error: %v", err)
+		return nil
+	}
+
+	config := &image.Config{
+		Name:   imageName,
+		Labels: utils.MergeLabels(containerInfo.Labels, labels),
+	}
+	if err = image.WriteConfig(config); err != nil {
+		return err
 	}
+	logContainerEvent(containerInfo, "commit", "imageName", imageName)
 	return nil
 }
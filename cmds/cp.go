@@ -0,0 +1,235 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/archive"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// hostRoot 宿主机一侧的路径同样通过 archive 包解析，root 为 /
+const hostRoot = "/"
+
+// Copy 在容器和宿主机之间复制文件，src 和 dst 中有且只有一个为 容器:路径 的形式
+/*
+1）容器中的路径在 merged 目录中解析，符号链接不会指向宿主机上的文件，volume 挂载在 merged 中，同样可以复制
+2）宿主机一侧为 - 时通过标准输出输出 tar 流，或者从标准输入读取 tar 流解压到容器中的目录
+3）保留属主、权限、符号链接和 xattr，followLink 为 true 时复制源路径符号链接指向的文件
+*/
+func Copy(src, dst string, followLink bool) error {
+	srcContainer, srcPath := splitCopyPath(src)
+	dstContainer, dstPath := splitCopyPath(dst)
+	switch {
+	case srcContainer != "" && dstContainer != "":
+		return fmt.Errorf("copying between containers is not supported")
+	case srcContainer != "":
+		return copyFromContainer(srcContainer, srcPath, dstPath, followLink)
+	case dstContainer != "":
+		return copyToContainer(srcPath, dstContainer, dstPath, followLink)
+	}
+	return fmt.Errorf("must specify at least one container source")
+}
+
+// splitCopyPath 把 容器:路径 拆开，以 / 或者 . 开头以及冒号前有 / 的都是宿主机路径
+func splitCopyPath(arg string) (string, string) {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	i := strings.Index(arg, ":")
+	if i <= 0 || strings.Contains(arg[:i], "/") {
+		return "", arg
+	}
+	return arg[:i], arg[i+1:]
+}
+
+func copyFromContainer(ref, srcPath, dstPath string, followLink bool) error {
+	if dstPath == "-" {
+		// 标准输出用于 tar 流，日志改为输出到标准错误
+		logrus.SetOutput(os.Stderr)
+	}
+	containerInfo, root, err := getContainerRoot(ref)
+	if err != nil {
+		return err
+	}
+	src, err := resolveCopySource(root, srcPath, followLink)
+	if err != nil {
+		return err
+	}
+
+	if dstPath == "-" {
+		reader := archive.Tar(src.path, src.name)
+		defer reader.Close()
+		if _, err = io.Copy(os.Stdout, reader); err != nil {
+			return fmt.Errorf("copy %s error: %w", srcPath, err)
+		}
+	} else if err = copyPath(src, hostRoot, hostPath(dstPath)); err != nil {
+		return err
+	}
+	logContainerEvent(containerInfo, "archive-path", "path", srcPath)
+	return nil
+}
+
+func copyToContainer(srcPath, ref, dstPath string, followLink bool) error {
+	containerInfo, root, err := getContainerRoot(ref)
+	if err != nil {
+		return err
+	}
+
+	if srcPath == "-" {
+		dst, err := resolveCopyDestination(root, dstPath)
+		if err != nil {
+			return err
+		}
+		if dst.info == nil || !dst.info.IsDir() {
+			return fmt.Errorf("destination %s must be a directory when copying from stdin", dstPath)
+		}
+		if err = archive.Untar(os.Stdin, root, dst.path); err != nil {
+			return err
+		}
+	} else {
+		src, err := resolveCopySource(hostRoot, hostPath(srcPath), followLink)
+		if err != nil {
+			return err
+		}
+		if err = copyPath(src, root, dstPath); err != nil {
+			return err
+		}
+	}
+	logContainerEvent(containerInfo, "extract-to-dir", "path", dstPath)
+	return nil
+}
+
+// getContainerRoot 返回容器信息和 merged 目录，merged 没有挂载时重新挂载，停止的容器同样可以复制
+func getContainerRoot(ref string) (*container.Info, string, error) {
+	containerId, err := ResolveContainerId(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return nil, "", fmt.Errorf("get container info error: %w", err)
+	}
+	if err = container.MountWorkSpace(containerId, containerInfo.Volume); err != nil {
+		return nil, "", err
+	}
+	return containerInfo, utils.GetMergedPath(containerId), nil
+}
+
+// hostPath 把宿主机上的相对路径转换为绝对路径，保留结尾的 / 和 /.
+func hostPath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+	return wd + "/" + p
+}
+
+type copySource struct {
+	// path 宿主机上的路径
+	path string
+	// name 在 tar 包中的名称，只复制目录中的内容时为 .
+	name string
+	info os.FileInfo
+}
+
+// resolveCopySource 解析 root 中的源路径
+/*
+1）默认复制符号链接本身，以 / 结尾或者 followLink 时复制链接指向的文件
+2）以 /. 结尾时只复制目录中的内容
+*/
+func resolveCopySource(root, p string, followLink bool) (*copySource, error) {
+	var err error
+	src := &copySource{name: filepath.Base(filepath.Clean("/" + p))}
+	if strings.HasSuffix(p, "/.") || p == "." || src.name == "/" {
+		src.name = "."
+	}
+	if followLink || strings.HasSuffix(p, "/") || src.name == "." {
+		src.path, err = archive.ResolveInScope(root, p)
+	} else {
+		src.path, err = archive.ResolveParentInScope(root, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s error: %w", p, err)
+	}
+	if src.info, err = os.Lstat(src.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no such file or directory: %s", p)
+		}
+		return nil, err
+	}
+	if src.name == "." && !src.info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", p)
+	}
+	return src, nil
+}
+
+type copyDestination struct {
+	// path root 中的路径，其中的符号链接都已经解析
+	path string
+	// info 为 nil 表示不存在
+	info os.FileInfo
+}
+
+func resolveCopyDestination(root, p string) (*copyDestination, error) {
+	resolved, err := archive.ResolveInScope(root, p)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s error: %w", p, err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return nil, err
+	}
+	dst := &copyDestination{path: filepath.Join("/", rel)}
+	if dst.info, err = os.Stat(resolved); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// copyPath 和 cp -a 的规则一样把 src 复制到 root 中的 dst
+/*
+1）dst 是已经存在的目录时复制到 dst 中
+2）dst 不存在时创建为 src 的副本，这时 dst 的父目录必须存在
+3）dst 是已经存在的文件时用 src 覆盖，src 为目录时报错
+*/
+func copyPath(src *copySource, root, dst string) error {
+	dstInfo, err := resolveCopyDestination(root, dst)
+	if err != nil {
+		return err
+	}
+
+	destDir, name := filepath.Dir(dstInfo.path), filepath.Base(dstInfo.path)
+	switch {
+	case dstInfo.info != nil && dstInfo.info.IsDir():
+		destDir, name = dstInfo.path, src.name
+	case dstInfo.info != nil:
+		if src.info.IsDir() {
+			return fmt.Errorf("cannot copy a directory to a file: %s", dst)
+		}
+	default:
+		if !src.info.IsDir() && strings.HasSuffix(dst, "/") {
+			return fmt.Errorf("destination directory %s does not exist", dst)
+		}
+		parent, err := resolveCopyDestination(root, destDir)
+		if err != nil {
+			return err
+		}
+		if parent.info == nil || !parent.info.IsDir() {
+			return fmt.Errorf("parent directory of %s does not exist", dst)
+		}
+	}
+
+	reader := archive.Tar(src.path, name)
+	defer reader.Close()
+	return archive.Untar(reader, root, destDir)
+}
@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// Diff 输出容器文件系统相对于镜像的变化，C 为修改，A 为新增，D 为删除，停止的容器同样可以查看
+func Diff(containerId string) error {
+	if _, err := getInfoByContainerId(containerId); err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	exist, err := utils.IsPathExist(utils.GetUpperPath(containerId))
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("workspace of container %s does not exist", containerId)
+	}
+
+	changes, err := container.GetChanges(containerId)
+	if err != nil {
+		return fmt.Errorf("get changes of container %s error: %w", containerId, err)
+	}
+	for _, change := range changes {
+		fmt.Fprintf(os.Stdout, "%s %s\n", change.Kind, change.Path)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/events"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// EventsOptions events 命令的参数
+type EventsOptions struct {
+	Since   string
+	Until   string
+	Filters []string
+	Format  string
+}
+
+// Events 输出事件日志中的事件
+/*
+和 docker 一样：
+1）没有指定 --until 时，输出完历史事件后继续等待新的事件
+2）指定了 --since 时只输出之后的事件，没有指定时只输出新的事件
+*/
+func Events(opts EventsOptions) error {
+	filters, err := parseFilters(opts.Filters, "type", "event", "container", "network", "label")
+	if err != nil {
+		return err
+	}
+	var tmpl *template.Template
+	if opts.Format != "" && opts.Format != FormatJson {
+		if tmpl, err = template.New("events").Parse(opts.Format); err != nil {
+			return fmt.Errorf("parse format error: %w", err)
+		}
+	}
+
+	now := time.Now()
+	since, until := now, time.Time{}
+	if opts.Since != "" {
+		if since, err = parseTimestamp("since", opts.Since, now); err != nil {
+			return err
+		}
+	}
+	if opts.Until != "" {
+		if until, err = parseTimestamp("until", opts.Until, now); err != nil {
+			return err
+		}
+	}
+
+	var execErr error
+	err = events.Watch(opts.Until == "", until, func(event *events.Event) bool {
+		if event.Time.Before(since) {
+			return true
+		}
+		if !until.IsZero() && event.Time.After(until) {
+			return false
+		}
+		if !matchEvent(event, filters) {
+			return true
+		}
+		switch {
+		case opts.Format == FormatJson:
+			fmt.Fprintln(os.Stdout, utils.Marshal(event))
+		case tmpl != nil:
+			if execErr = tmpl.Execute(os.Stdout, event); execErr != nil {
+				return false
+			}
+			fmt.Fprintln(os.Stdout)
+		default:
+			fmt.Fprintln(os.Stdout, formatEvent(event))
+		}
+		return true
+	})
+	if execErr != nil {
+		return fmt.Errorf("execute format error: %w", execErr)
+	}
+	return err
+}
+
+func matchEvent(event *events.Event, filters filterArgs) bool {
+	return filters.match("type", func(v string) bool { return event.Type == v }) &&
+		filters.match("event", func(v string) bool { return event.Action == v }) &&
+		filters.match("container", func(v string) bool {
+			// 网络事件的 container 属性记录了对应的容器
+			id := event.Attributes["container"]
+			if event.Type == events.TypeContainer {
+				id = event.ID
+			}
+			return (id != "" && strings.HasPrefix(id, v)) || event.Attributes["name"] == v
+		}) &&
+		filters.match("network", func(v string) bool {
+			return event.Type == events.TypeNetwork && event.ID == v
+		}) &&
+		// 容器、网络和镜像的 label 以 label. 为前缀记录在事件的属性中
+		filters.matchLabels(event.Labels())
+}
+
+// formatEvent 默认的输出格式，比如 2006-01-02T15:04:05.000000000Z container die abc (exitCode=0, name=test)
+func formatEvent(event *events.Event) string {
+	line := fmt.Sprintf("%s %s %s %s", event.Time.Format(time.RFC3339Nano), event.Type, event.Action, event.ID)
+	if len(event.Attributes) == 0 {
+		return line
+	}
+	attributes := make([]string, 0, len(event.Attributes))
+	for k, v := range event.Attributes {
+		attributes = append(attributes, k+"="+v)
+	}
+	sort.Strings(attributes)
+	return fmt.Sprintf("%s (%s)", line, strings.Join(attributes, ", "))
+}
+
+// logContainerEvent 记录容器事件，属性中包括容器的 label，attributes 为 key、value 交替的额外属性
+func logContainerEvent(containerInfo *container.Info, action string, attributes ...string) {
+	attrs := map[string]string{}
+	events.AddLabels(attrs, containerInfo.Labels)
+	attrs["name"] = containerInfo.Name
+	attrs["image"] = containerInfo.Image
+	for i := 0; i+1 < len(attributes); i += 2 {
+		attrs[attributes[i]] = attributes[i+1]
+	}
+	events.Log(events.TypeContainer, action, containerInfo.Id, attrs)
+}
@@ -1,11 +1,9 @@
 package cmds
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"path"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -15,49 +13,50 @@ import (
 )
 
 const (
-	EnvExecPid = "mydocker_pid"
-	EnvExecCmd = "mydocker_cmd"
+	EnvExecPid   = "mydocker_pid"
+	EnvExecCmd   = "mydocker_cmd"
+	EnvExecQuiet = "mydocker_quiet"
 )
 
 // nsenter里的C代码里已经出现mydocker_pid和mydocker_cmd这两个Key,主要是为了控制是否执行C代码里面的setns.
 func ExecContainer(containerId string, cmds []string) {
-	pid, err := getPidByContainerId(containerId)
+	containerInfo, err := getInfoByContainerId(containerId)
 	if err != nil {
-		logrus.Errorf("get pid from container id error: %v", err)
+		logrus.Errorf("get container info error: %v", err)
 		return
 	}
+	switch containerInfo.Status {
+	case container.RUNNING:
+	case container.PAUSED:
+		logrus.Errorf("container %s is paused, unpause it before exec", containerId)
+		return
+	default:
+		logrus.Errorf("container %s is not running", containerId)
+		return
+	}
+	pid := containerInfo.Pid
 
-	cmd := exec.Command("/proc/self/exe", "exec")
+	cmdStr := strings.Join(cmds, " ")
+	logrus.Infof("container pid: %s, command: %s", pid, cmdStr)
+	cmd := newExecCommand(pid, cmdStr)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	cmdStr := strings.Join(cmds, " ")
-	logrus.Infof("container pid: %s, command: %s", pid, cmdStr)
-	os.Setenv(EnvExecPid, pid)
-	os.Setenv(EnvExecCmd, cmdStr)
-
-	// 把指定PID进程的环境变量传递给新启动的进程，实现通过exec命令也能查询到容器的环境变量
-	containerEnv := getEnvsByPid(pid)
-	cmd.Env = append(os.Environ(), containerEnv...)
-
 	if err = cmd.Run(); err != nil {
 		logrus.Errorf("exec container %s error: %v", containerId, err)
 	}
 }
 
-func getPidByContainerId(containerId string) (string, error) {
-	folder := fmt.Sprintf(container.InfoLocFormat, containerId)
-	infoFilePath := path.Join(folder, container.ConfigName)
-	contentBytes, err := os.ReadFile(infoFilePath)
-	if err != nil {
-		return "", err
-	}
-	containerInfo := &container.Info{}
-	if err := json.Unmarshal(contentBytes, containerInfo); err != nil {
-		return "", err
-	}
-	return containerInfo.Pid, nil
+// newExecCommand 生成在容器 pid 的 namespace 中执行 cmdStr 的命令，进程启动时由 nsenter 中的 C 代码完成 setns
+func newExecCommand(pid, cmdStr string, extraEnv ...string) *exec.Cmd {
+	cmd := exec.Command("/proc/self/exe", "exec")
+	// 把指定PID进程的环境变量传递给新启动的进程，实现通过exec命令也能查询到容器的环境变量
+	containerEnv := getEnvsByPid(pid)
+	cmd.Env = append(os.Environ(), containerEnv...)
+	cmd.Env = append(cmd.Env, EnvExecPid+"="+pid, EnvExecCmd+"="+cmdStr)
+	cmd.Env = append(cmd.Env, extraEnv...)
+	return cmd
 }
 
 func getEnvsByPid(pid string) []string {
@@ -0,0 +1,75 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/archive"
+	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// Export 把容器合并之后的 rootfs 打包为一个 tar 包，output 为空或者 - 时输出到标准输出
+/*
+1）和 commit 不同，只导出文件系统，不生成镜像，可以在其他主机上通过 import 导入
+2）volume 中的数据不属于容器的文件系统，只保留挂载点目录
+3）停止的容器同样可以导出
+*/
+func Export(containerId, output string) error {
+	toStdout := output == "" || output == "-"
+	if toStdout {
+		if utils.IsTerminal(os.Stdout) {
+			return fmt.Errorf("refusing to write tar archive to a terminal, use -o or redirect the output")
+		}
+		// 标准输出用于 tar 流，日志改为输出到标准错误
+		logrus.SetOutput(os.Stderr)
+	}
+
+	containerInfo, root, err := getContainerRoot(containerId)
+	if err != nil {
+		return err
+	}
+	var excludes []string
+	if containerInfo.Volume != "" {
+		containerPath, err := container.GetVolumeContainerPath(containerInfo.Volume)
+		if err != nil {
+			return err
+		}
+		excludes = append(excludes, strings.TrimPrefix(filepath.Clean(containerPath), "/"))
+	}
+
+	reader := archive.Tar(root, ".", excludes...)
+	defer reader.Close()
+	if toStdout {
+		_, err = io.Copy(os.Stdout, reader)
+	} else {
+		err = writeArchive(output, reader)
+	}
+	if err != nil {
+		return fmt.Errorf("export container %s error: %w", containerId, err)
+	}
+	logContainerEvent(containerInfo, "export")
+	return nil
+}
+
+// writeArchive 把 tar 流写入文件，出错时删除写了一半的文件
+func writeArchive(file string, reader io.Reader) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, constant.Perm0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, reader)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+	}
+	return err
+}
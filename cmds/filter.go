@@ -0,0 +1,101 @@
+package cmds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// filterArgs 对应 --filter 参数，同一个 key 的多个值之间是或的关系，不同 key 之间是与的关系
+type filterArgs map[string][]string
+
+// parseFilters 解析 --filter 参数，每个参数可以是 key=value，也可以是逗号分隔的多个 key=value
+func parseFilters(filters []string, validKeys ...string) (filterArgs, error) {
+	args := filterArgs{}
+	for _, filter := range filters {
+		for _, kv := range strings.Split(filter, ",") {
+			if kv == "" {
+				continue
+			}
+			key, value, found := strings.Cut(kv, "=")
+			if !found || key == "" {
+				return nil, fmt.Errorf("invalid filter %s, must be key=value", kv)
+			}
+			if !isValidFilterKey(key, validKeys) {
+				return nil, fmt.Errorf("invalid filter key %s, must be one of %s", key, strings.Join(validKeys, ", "))
+			}
+			args[key] = append(args[key], value)
+		}
+	}
+	return args, nil
+}
+
+func isValidFilterKey(key string, validKeys []string) bool {
+	for _, k := range validKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contains 是否指定了 key 对应的过滤条件
+func (args filterArgs) contains(key string) bool {
+	_, ok := args[key]
+	return ok
+}
+
+// match 没有指定 key 时总是匹配，否则只要有一个值满足 fn 即匹配
+func (args filterArgs) match(key string, fn func(value string) bool) bool {
+	values, ok := args[key]
+	if !ok {
+		return true
+	}
+	for _, value := range values {
+		if fn(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels 和其他过滤条件不同，多个 label 条件之间是与的关系，和 docker 一致
+func (args filterArgs) matchLabels(labels map[string]string) bool {
+	for _, value := range args["label"] {
+		if !utils.MatchLabel(labels, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTimestamp 解析 until、since 等时间参数，支持相对时长（如 24h）、unix 时间戳和 RFC3339、container.TimeFormat 格式的时间
+// name 为参数名称，用于错误信息
+func parseTimestamp(name, value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(container.TimeFormat, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %s, must be a duration, unix timestamp or RFC3339 time", name, value)
+}
+
+// matchUntil 没有指定 until 时总是匹配，否则只匹配在 until 之前创建的对象
+func (args filterArgs) matchUntil(created time.Time) bool {
+	now := time.Now()
+	return args.match("until", func(value string) bool {
+		until, err := parseTimestamp("until", value, now)
+		return err == nil && created.Before(until)
+	})
+}
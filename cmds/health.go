@@ -0,0 +1,145 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// startHealthCheck 按照配置周期性地在容器中执行健康检查命令，返回的函数用来停止健康检查
+/*
+健康检查由看护容器的进程（monitor 或者前台运行的 run 进程）执行：
+1）容器启动后状态为 starting，检查成功一次变为 healthy，连续失败 retries 次变为 unhealthy
+2）暂停的容器不做检查
+3）配置了 RestartOnUnhealthy 时，变为 unhealthy 后杀死容器，由 monitor 重新启动
+*/
+func startHealthCheck(containerId string, config *container.HealthConfig) func() {
+	if config == nil || config.Cmd == "" {
+		return func() {}
+	}
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				checkContainerHealth(containerId, config)
+			}
+		}
+	}()
+	return func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// checkContainerHealth 执行一次健康检查并记录结果
+func checkContainerHealth(containerId string, config *container.HealthConfig) {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		logrus.Errorf("get container info error: %v", err)
+		return
+	}
+	if containerInfo.Status != container.RUNNING || containerInfo.Pid == "" {
+		return
+	}
+
+	result := runHealthCmd(containerInfo.Pid, config)
+	var becameUnhealthy bool
+	var previous string
+	containerInfo, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		// 检查期间容器可能已经退出或者被重启
+		if info.Pid != containerInfo.Pid {
+			return errNotRunning
+		}
+		if info.Health == nil {
+			info.Health = &container.Health{Status: container.HealthStarting}
+		}
+		previous = info.Health.Status
+		info.Health.AddResult(result, config.Retries)
+		becameUnhealthy = previous != container.HealthUnhealthy && info.Health.Status == container.HealthUnhealthy
+		return nil
+	})
+	if err != nil {
+		if err != errNotRunning {
+			logrus.Errorf("update container health error: %v", err)
+		}
+		return
+	}
+
+	if previous != containerInfo.Health.Status {
+		logContainerEvent(containerInfo, "health_status", "healthStatus", containerInfo.Health.Status)
+	}
+	if becameUnhealthy {
+		logrus.Warnf("container %s is unhealthy", containerId)
+		if config.RestartOnUnhealthy {
+			pid, _ := strconv.Atoi(containerInfo.Pid)
+			if err = syscall.Kill(pid, syscall.SIGKILL); err != nil {
+				logrus.Errorf("kill unhealthy container %s error: %v", containerId, err)
+			}
+		}
+	}
+}
+
+// runHealthCmd 通过 nsenter 在容器中执行健康检查命令，超时后杀死整个进程组
+func runHealthCmd(pid string, config *container.HealthConfig) (result container.HealthResult) {
+	result.Start = time.Now().Format(time.RFC3339Nano)
+	defer func() {
+		result.End = time.Now().Format(time.RFC3339Nano)
+	}()
+
+	var output bytes.Buffer
+	cmd := newExecCommand(pid, config.Cmd, EnvExecQuiet+"=1")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// 命令由 nsenter 中的 system() 在子进程中执行，超时后需要杀死整个进程组
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// 脱离进程组的子进程可能一直持有输出管道，不再等待它们
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("start health check error: %v", err)
+		return
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+	timer := time.NewTimer(config.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-waitCh:
+		result.ExitCode = healthExitCode(cmd, err)
+		result.Output = output.String()
+	case <-timer.C:
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-waitCh
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("health check exceeded timeout (%v)", config.Timeout)
+	}
+	return
+}
+
+func healthExitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
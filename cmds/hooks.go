@@ -0,0 +1,55 @@
+package cmds
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// runContainerHooks 依次执行全局 hook 目录和容器自己配置的某个阶段的 hook
+/*
+各个阶段的执行时机和失败时的处理：
+1）createRuntime、prestart：init 进程已经创建、cgroup 和网络已经配置，用户命令还没有执行，
+   失败时杀死容器并执行 poststop，本次启动失败
+2）poststart：用户命令开始执行之后，失败时只打印日志
+3）poststop：容器每次退出、资源释放之后，记录退出状态之前，失败时只打印日志
+*/
+func runContainerHooks(containerInfo *container.Info, stage, status string) error {
+	return container.RunHooks(stage, getContainerHooks(containerInfo, stage), newContainerState(containerInfo, status))
+}
+
+func getContainerHooks(containerInfo *container.Info, stage string) []container.Hook {
+	hooks := container.LoadHooksDir(container.HooksDir).Get(stage)
+	return append(hooks, containerInfo.Hooks.Get(stage)...)
+}
+
+// getHooksTimeout 依次执行某个阶段所有 hook 最多需要的时间
+func getHooksTimeout(containerInfo *container.Info, stage string) time.Duration {
+	var timeout time.Duration
+	for _, hook := range getContainerHooks(containerInfo, stage) {
+		timeout += hook.GetTimeout()
+	}
+	return timeout
+}
+
+// newContainerState 生成传递给 hook 的容器状态
+func newContainerState(containerInfo *container.Info, status string) *container.State {
+	pid, _ := strconv.Atoi(containerInfo.Pid)
+	return &container.State{
+		OCIVersion: container.OCIVersion,
+		ID:         containerInfo.Id,
+		Status:     status,
+		Pid:        pid,
+		Bundle:     fmt.Sprintf(container.InfoLocFormat, containerInfo.Id),
+		Annotations: map[string]string{
+			"name":    containerInfo.Name,
+			"image":   containerInfo.Image,
+			"rootfs":  utils.GetMergedPath(containerInfo.Id),
+			"network": containerInfo.NetworkName,
+			"ip":      containerInfo.IP,
+		},
+	}
+}
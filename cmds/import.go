@@ -0,0 +1,122 @@
+package cmds
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/events"
+	"github.com/wangstu/mydocker/image"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// imageNamePattern 镜像名称用作文件名，不能包含 /，name:tag 整体作为镜像名称
+var imageNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:-]*$`)
+
+// Import 把 export 导出的 rootfs tar 包注册为镜像，source 为 - 时从标准输入读取
+/*
+1）tar 包可以是 gzip 压缩的，先写入镜像目录中的临时文件，检查是合法的 tar 包之后再替换同名的镜像
+2）changes 为 Dockerfile 指令，设置镜像的默认命令、环境变量和 label
+*/
+func Import(source, imageName string, changes []string) error {
+	if !imageNamePattern.MatchString(imageName) {
+		return fmt.Errorf("invalid image name %q, only [a-zA-Z0-9][a-zA-Z0-9_.:-] are allowed", imageName)
+	}
+	config := &image.Config{Name: imageName}
+	if err := image.ApplyChanges(config, changes); err != nil {
+		return err
+	}
+
+	reader := os.Stdin
+	if source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			return fmt.Errorf("open %s error: %w", source, err)
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	if err := os.MkdirAll(utils.ImagePath, constant.Perm0755); err != nil {
+		return fmt.Errorf("mkdir %s error: %w", utils.ImagePath, err)
+	}
+	tmp, err := os.CreateTemp(utils.ImagePath, ".import-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	// 临时文件的权限为 0600，和 commit 生成的镜像保持一致
+	if err = tmp.Chmod(constant.Perm0644); err == nil {
+		_, err = io.Copy(tmp, reader)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("read %s error: %w", source, err)
+	}
+	if err = checkArchive(tmp.Name()); err != nil {
+		return fmt.Errorf("invalid tar archive %s: %w", source, err)
+	}
+
+	tarImagePath := utils.GetImagePath(imageName)
+	if exist, err := utils.IsPathExist(tarImagePath); err == nil && exist {
+		logrus.Warnf("%s is existed, overwrite it", tarImagePath)
+	}
+	if err = os.Rename(tmp.Name(), tarImagePath); err != nil {
+		return fmt.Errorf("save image %s error: %w", imageName, err)
+	}
+	if err = image.WriteConfig(config); err != nil {
+		return err
+	}
+
+	attrs := map[string]string{"source": source}
+	events.AddLabels(attrs, config.Labels)
+	events.Log(events.TypeImage, "import", imageName, attrs)
+	logrus.Infof("image %s imported from %s", imageName, source)
+	return nil
+}
+
+// checkArchive 读取 tar 包中所有的项，gzip 压缩的先解压
+func checkArchive(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buffered := bufio.NewReader(f)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	entries := 0
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		entries++
+	}
+	if entries == 0 {
+		return fmt.Errorf("empty archive")
+	}
+	return nil
+}
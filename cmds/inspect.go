@@ -0,0 +1,217 @@
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/image"
+	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
+)
+
+const (
+	InspectTypeContainer = "container"
+	InspectTypeNetwork   = "network"
+	InspectTypeImage     = "image"
+)
+
+type containerInspect struct {
+	*container.Info
+	CgroupPath  string            `json:"cgroupPath"`
+	GraphDriver map[string]string `json:"graphDriver"`
+	Mounts      []mountPoint      `json:"mounts"`
+}
+
+type mountPoint struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type networkInspect struct {
+	*network.Network
+	Subnet     string                      `json:"subnet"`
+	Gateway    string                      `json:"gateway"`
+	Containers map[string]networkContainer `json:"containers"`
+}
+
+type networkContainer struct {
+	Name        string                  `json:"name"`
+	IP          string                  `json:"ip"`
+	PortMapping []string                `json:"portMapping"`
+	Ports       []container.PortBinding `json:"ports,omitempty"`
+}
+
+type imageInspect struct {
+	Name    string            `json:"name"`
+	Path    string            `json:"path"`
+	Size    int64             `json:"size"`
+	Created string            `json:"created"`
+	Labels  map[string]string `json:"labels"`
+	Cmd     []string          `json:"cmd,omitempty"`
+	Env     []string          `json:"env,omitempty"`
+}
+
+// Inspect 以 JSON 或者 Go template 格式输出容器、网络或者镜像的详细信息
+// objType 为空时依次按容器、网络、镜像查找
+func Inspect(refs []string, objType, format string) error {
+	var tmpl *template.Template
+	if format != "" {
+		var err error
+		tmpl, err = template.New("inspect").Funcs(template.FuncMap{
+			"json": func(v interface{}) string { return utils.Marshal(v) },
+		}).Parse(format)
+		if err != nil {
+			return fmt.Errorf("parse format error: %w", err)
+		}
+	}
+
+	objs := make([]interface{}, 0, len(refs))
+	var failed []string
+	for _, ref := range refs {
+		obj, err := inspectObject(ref, objType)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = append(failed, ref)
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	if tmpl != nil {
+		for _, obj := range objs {
+			if err := tmpl.Execute(os.Stdout, obj); err != nil {
+				return fmt.Errorf("execute format error: %w", err)
+			}
+			fmt.Fprintln(os.Stdout)
+		}
+	} else {
+		content, err := json.MarshalIndent(objs, "", "    ")
+		if err != nil {
+			return fmt.Errorf("marshal inspect result error: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(content))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("no such object: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func inspectObject(ref, objType string) (interface{}, error) {
+	switch objType {
+	case InspectTypeContainer:
+		return inspectContainer(ref)
+	case InspectTypeNetwork:
+		return inspectNetwork(ref)
+	case InspectTypeImage:
+		return inspectImage(ref)
+	case "":
+		if obj, err := inspectContainer(ref); err == nil {
+			return obj, nil
+		}
+		if obj, err := inspectNetwork(ref); err == nil {
+			return obj, nil
+		}
+		if obj, err := inspectImage(ref); err == nil {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("no such object: %s", ref)
+	default:
+		return nil, fmt.Errorf("invalid type %s, must be one of container, network and image", objType)
+	}
+}
+
+func inspectContainer(ref string) (*containerInspect, error) {
+	containerId, err := ResolveContainerId(ref)
+	if err != nil {
+		return nil, err
+	}
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return nil, fmt.Errorf("get container info error: %w", err)
+	}
+
+	result := &containerInspect{
+		Info:       containerInfo,
+		CgroupPath: getCgroupPath(containerId),
+		GraphDriver: map[string]string{
+			"name":      "overlay2",
+			"lowerDir":  utils.GetLowerPath(containerId),
+			"upperDir":  utils.GetUpperPath(containerId),
+			"workDir":   utils.GetWorkPath(containerId),
+			"mergedDir": utils.GetMergedPath(containerId),
+		},
+		Mounts: []mountPoint{},
+	}
+	if containerInfo.Volume != "" {
+		if hostPath, containerPath, found := strings.Cut(containerInfo.Volume, ":"); found {
+			result.Mounts = append(result.Mounts, mountPoint{Source: hostPath, Destination: containerPath})
+		}
+	}
+	return result, nil
+}
+
+func inspectNetwork(name string) (*networkInspect, error) {
+	nw, err := network.GetNetwork(name)
+	if err != nil {
+		return nil, err
+	}
+	result := &networkInspect{
+		Network:    nw,
+		Containers: map[string]networkContainer{},
+	}
+	if nw.IPRange != nil {
+		result.Gateway = nw.IPRange.IP.String()
+		if _, subnet, err := net.ParseCIDR(nw.IPRange.String()); err == nil {
+			result.Subnet = subnet.String()
+		}
+	}
+
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range containerInfos {
+		if info.NetworkName != name {
+			continue
+		}
+		result.Containers[info.Id] = networkContainer{
+			Name:        info.Name,
+			IP:          info.IP,
+			PortMapping: info.PortMapping,
+			Ports:       info.Ports,
+		}
+	}
+	return result, nil
+}
+
+func inspectImage(name string) (*imageInspect, error) {
+	imagePath := utils.GetImagePath(name)
+	stat, err := os.Stat(imagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no such image: %s", name)
+		}
+		return nil, fmt.Errorf("stat image %s error: %w", imagePath, err)
+	}
+	config, err := image.ReadConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return &imageInspect{
+		Name:    name,
+		Path:    imagePath,
+		Size:    stat.Size(),
+		Created: stat.ModTime().Format(time.RFC3339),
+		Labels:  config.Labels,
+		Cmd:     config.Cmd,
+		Env:     config.Env,
+	}, nil
+}
@@ -0,0 +1,34 @@
+package cmds
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// KillContainer 向容器的 init 进程发送任意信号，不修改容器状态
+// 如果信号导致容器退出，由 monitor 记录退出状态并按重启策略处理
+func KillContainer(containerId string, signal syscall.Signal) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running", containerId)
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("convert pid error: %w", err)
+	}
+
+	if err = syscall.Kill(pid, signal); err != nil {
+		return fmt.Errorf("send signal %d to container %s error: %w", signal, containerId, err)
+	}
+	logrus.Infof("send signal %d to container %s", signal, containerId)
+	logContainerEvent(containerInfo, "kill", "signal", strconv.Itoa(int(signal)))
+	return nil
+}
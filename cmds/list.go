@@ -1,65 +1,218 @@
 package cmds
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path"
+	"strings"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
 )
 
-func ListContainers() {
-	entries, err := os.ReadDir(container.InfoLoc)
+const (
+	FormatTable = "table"
+	FormatJson  = "json"
+
+	truncCommandLength = 20
+)
+
+// ListOptions ps 命令的参数
+type ListOptions struct {
+	All     bool
+	Quiet   bool
+	NoTrunc bool
+	Filters []string
+	Format  string
+}
+
+// containerRow ps 输出的一行，--format 中的模板以及 json 格式都基于这个结构
+type containerRow struct {
+	ID         string `json:"ID"`
+	Name       string `json:"Name"`
+	Image      string `json:"Image"`
+	Pid        string `json:"Pid"`
+	IP         string `json:"IP"`
+	Network    string `json:"Network"`
+	State      string `json:"State"`
+	Status     string `json:"Status"`
+	Command    string `json:"Command"`
+	CreatedAt  string `json:"CreatedAt"`
+	RunningFor string `json:"RunningFor"`
+	Ports      string `json:"Ports"`
+	Labels     string `json:"Labels"`
+}
+
+func ListContainers(opts ListOptions) error {
+	filters, err := parseFilters(opts.Filters, "id", "name", "status", "network", "health", "label")
 	if err != nil {
-		logrus.Errorf("read dir %s error: %v", container.InfoLoc, err)
-		return
+		return err
+	}
+	var tmpl *template.Template
+	if opts.Format != "" && opts.Format != FormatTable && opts.Format != FormatJson {
+		if tmpl, err = template.New("ps").Parse(opts.Format); err != nil {
+			return fmt.Errorf("parse format error: %w", err)
+		}
 	}
 
-	containerInfos := make([]*container.Info, 0, len(entries))
-	for _, entry := range entries {
-		tmpInfo, err := getContainerInfo(entry)
-		if err != nil {
-			logrus.Errorf("get container info error: %v", err)
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return fmt.Errorf("list containers error: %w", err)
+	}
+
+	now := time.Now()
+	rows := make([]*containerRow, 0, len(containerInfos))
+	for _, item := range containerInfos {
+		if !matchContainer(item, filters, opts.All) {
 			continue
 		}
-		containerInfos = append(containerInfos, tmpInfo)
+		rows = append(rows, newContainerRow(item, now, opts.NoTrunc))
+	}
+
+	switch {
+	case opts.Quiet:
+		for _, row := range rows {
+			fmt.Fprintln(os.Stdout, row.ID)
+		}
+	case opts.Format == FormatJson:
+		for _, row := range rows {
+			fmt.Fprintln(os.Stdout, utils.Marshal(row))
+		}
+	case tmpl != nil:
+		for _, row := range rows {
+			if err = tmpl.Execute(os.Stdout, row); err != nil {
+				return fmt.Errorf("execute format error: %w", err)
+			}
+			fmt.Fprintln(os.Stdout)
+		}
+	default:
+		printContainerTable(rows)
 	}
+	return nil
+}
 
+func printContainerTable(rows []*containerRow) {
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	if _, err = fmt.Fprint(w, "ID\tNAME\tPID\tIP\tSTATUS\tCOMMAND\tCREATED\n"); err != nil {
+	if _, err := fmt.Fprint(w, "ID\tNAME\tPID\tIP\tSTATUS\tCOMMAND\tCREATED\tPORTS\n"); err != nil {
 		logrus.Errorf("fprint error: %v", err)
 	}
 
-	for _, item := range containerInfos {
-		if _, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+	for _, item := range rows {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.ID,
 			item.Name,
 			item.Pid,
 			item.IP,
 			item.Status,
 			item.Command,
-			item.CreateTime); err != nil {
+			item.RunningFor,
+			item.Ports); err != nil {
 			logrus.Errorf("fprint error: %v", err)
 		}
 	}
 	w.Flush()
 }
 
+// matchContainer 默认只显示运行中的容器，指定了 status 过滤条件或者 -a 时显示全部
+func matchContainer(info *container.Info, filters filterArgs, all bool) bool {
+	if !all && !filters.contains("status") && !isContainerActive(info) {
+		return false
+	}
+	return filters.match("id", func(v string) bool { return strings.HasPrefix(info.Id, v) }) &&
+		filters.match("name", func(v string) bool { return strings.Contains(info.Name, v) }) &&
+		filters.match("status", func(v string) bool { return info.Status == v }) &&
+		filters.match("network", func(v string) bool { return info.NetworkName == v }) &&
+		filters.match("health", func(v string) bool { return healthStatus(info) == v }) &&
+		filters.matchLabels(info.Labels)
+}
+
+// healthStatus 没有配置健康检查的容器为 none
+func healthStatus(info *container.Info) string {
+	if info.Health == nil {
+		return "none"
+	}
+	return info.Health.Status
+}
+
+// isContainerActive 运行中、暂停和等待重启的容器都算活跃的容器
+func isContainerActive(info *container.Info) bool {
+	switch info.Status {
+	case container.RUNNING, container.PAUSED, container.RESTARTING:
+		return true
+	default:
+		return false
+	}
+}
+
+func newContainerRow(info *container.Info, now time.Time, noTrunc bool) *containerRow {
+	command := info.Command
+	if !noTrunc && len(command) > truncCommandLength {
+		command = command[:truncCommandLength-3] + "..."
+	}
+	row := &containerRow{
+		ID:        info.Id,
+		Name:      info.Name,
+		Image:     info.Image,
+		Pid:       info.Pid,
+		IP:        info.IP,
+		Network:   info.NetworkName,
+		State:     info.Status,
+		Status:    humanStatus(info, now),
+		Command:   command,
+		CreatedAt: info.CreateTime,
+		Ports:     container.FormatPortBindings(info.Ports),
+		Labels:    utils.FormatLabels(info.Labels),
+	}
+	if created, err := parseTime(info.CreateTime); err == nil {
+		row.RunningFor = utils.HumanDuration(now.Sub(created)) + " ago"
+	}
+	return row
+}
+
+// humanStatus 生成类似 "Up 5 minutes"、"Exited (0) 3 seconds ago" 的状态描述
+func humanStatus(info *container.Info, now time.Time) string {
+	switch info.Status {
+	case container.RUNNING, container.PAUSED:
+		started, err := parseTime(info.StartedAt)
+		if err != nil {
+			return "Up"
+		}
+		status := "Up " + utils.HumanDuration(now.Sub(started))
+		if info.Status == container.PAUSED {
+			status += " (Paused)"
+		} else if info.Health != nil {
+			status += fmt.Sprintf(" (%s)", info.Health.Status)
+		}
+		return status
+	case container.Exit, container.STOP, container.RESTARTING:
+		prefix := "Exited"
+		if info.Status == container.RESTARTING {
+			prefix = "Restarting"
+		}
+		finished, err := parseTime(info.FinishedAt)
+		if err != nil {
+			return fmt.Sprintf("%s (%d)", prefix, info.ExitCode)
+		}
+		return fmt.Sprintf("%s (%d) %s ago", prefix, info.ExitCode, utils.HumanDuration(now.Sub(finished)))
+	case container.CREATED:
+		return "Created"
+	default:
+		return info.Status
+	}
+}
+
+func parseTime(t string) (time.Time, error) {
+	return time.ParseInLocation(container.TimeFormat, t, time.Local)
+}
+
 func getContainerInfo(entry os.DirEntry) (*container.Info, error) {
-	folder := fmt.Sprintf(container.InfoLocFormat, entry.Name())
-	infoFilePath := path.Join(folder, container.ConfigName)
-	content, err := os.ReadFile(infoFilePath)
+	info, err := getInfoByContainerId(entry.Name())
 	if err != nil {
-		logrus.Errorf("read file %s error: %v", infoFilePath, err)
-		return nil, err
-	}
-	info := &container.Info{}
-	if err = json.Unmarshal(content, info); err != nil {
-		logrus.Errorf("json unmarshal error: %v", err)
+		logrus.Errorf("get container %s info error: %v", entry.Name(), err)
 		return nil, err
 	}
 	return info, nil
@@ -0,0 +1,262 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/cgroups"
+	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
+)
+
+const (
+	monitorPipeIndex = 3
+	monitorLogName   = "monitor.log"
+
+	// 重启间隔从 100ms 开始指数增长，容器稳定运行超过 10s 后重置
+	restartBackoffInitial = 100 * time.Millisecond
+	restartBackoffMax     = time.Minute
+	restartBackoffReset   = 10 * time.Second
+)
+
+// startMonitor 启动一个常驻的 monitor 进程来运行后台容器
+/*
+monitor 进程是容器 init 进程的父进程，负责：
+1）启动容器，并通过管道把启动结果告诉当前进程
+2）等待容器退出，回收 init 进程，记录退出码、退出时间等信息
+*/
+func startMonitor(containerId string) error {
+	readPipe, writePipe, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error: %w", err)
+	}
+	defer readPipe.Close()
+
+	folder := fmt.Sprintf(container.InfoLocFormat, containerId)
+	logFilePath := path.Join(folder, monitorLogName)
+	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, constant.Perm0644)
+	if err != nil {
+		writePipe.Close()
+		return fmt.Errorf("open monitor log file %s error: %w", logFilePath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command("/proc/self/exe", "monitor", containerId)
+	// 使用新的 session，当前终端退出后 monitor 进程不受影响
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.ExtraFiles = []*os.File{writePipe}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err = cmd.Start(); err != nil {
+		writePipe.Close()
+		return fmt.Errorf("start monitor process error: %w", err)
+	}
+	writePipe.Close()
+	_ = cmd.Process.Release()
+
+	// monitor 启动容器失败时会把错误信息写回管道，成功则直接关闭管道
+	msg, err := io.ReadAll(readPipe)
+	if err != nil {
+		return fmt.Errorf("read monitor status error: %w", err)
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("%s", msg)
+	}
+
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Pid == "" {
+		return fmt.Errorf("container %s exited unexpectedly, see %s", containerId, logFilePath)
+	}
+	return nil
+}
+
+// MonitorContainer monitor 进程的入口，启动容器并等待其退出
+func MonitorContainer(containerId string) error {
+	statusPipe := os.NewFile(uintptr(monitorPipeIndex), "pipe")
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		statusPipe.WriteString(err.Error())
+		statusPipe.Close()
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	setMonitorProcess(containerInfo)
+
+	parent, err := launchContainer(false, containerInfo)
+	if err != nil {
+		statusPipe.WriteString(err.Error())
+		statusPipe.Close()
+		return err
+	}
+	statusPipe.Close()
+
+	backoff := restartBackoffInitial
+	for {
+		startedAt := time.Now()
+		stopHealthCheck := startHealthCheck(containerId, containerInfo.HealthConfig)
+		exitCode := waitInitProcess(parent)
+		stopHealthCheck()
+		logrus.Infof("container %s exited with code %d", containerId, exitCode)
+		containerInfo = recordContainerExit(containerId, exitCode)
+		if containerInfo == nil || !shouldRestart(containerInfo) {
+			autoRemoveContainer(containerInfo)
+			return nil
+		}
+
+		if time.Since(startedAt) >= restartBackoffReset {
+			backoff = restartBackoffInitial
+		}
+		if _, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+			// 记录退出状态之后容器可能已经被 stop
+			if info.Status != container.Exit {
+				return errNotRunning
+			}
+			info.Status = container.RESTARTING
+			return nil
+		}); err != nil {
+			logrus.Infof("container %s is stopped or removed, give up restarting", containerId)
+			return nil
+		}
+		logrus.Infof("restart container %s in %v", containerId, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, restartBackoffMax)
+
+		// 等待期间容器可能已被 stop 或 rm
+		containerInfo, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+			if info.Status != container.RESTARTING {
+				return errNotRunning
+			}
+			info.RestartCount++
+			return nil
+		})
+		if err != nil {
+			logrus.Infof("container %s is stopped or removed, give up restarting", containerId)
+			return nil
+		}
+		if parent, err = launchContainer(false, containerInfo); err != nil {
+			logrus.Errorf("restart container %s error: %v", containerId, err)
+			// 启动失败时 launchContainer 已经释放了资源
+			saveContainerExit(containerId, -1, false)
+			return err
+		}
+	}
+}
+
+// setMonitorProcess 记录负责看护容器的进程，reconcile 时据此判断是否还有进程会记录容器的退出状态
+func setMonitorProcess(containerInfo *container.Info) {
+	containerInfo.MonitorPid = os.Getpid()
+	if startTime, err := utils.GetProcessStartTime(containerInfo.MonitorPid); err == nil {
+		containerInfo.MonitorStartTime = startTime
+	}
+}
+
+// autoRemoveContainer 删除已经退出且设置了 --rm 的容器
+func autoRemoveContainer(containerInfo *container.Info) {
+	if containerInfo == nil || !containerInfo.AutoRemove {
+		return
+	}
+	if err := RemoveContainer(containerInfo.Id, false); err != nil {
+		logrus.Errorf("auto remove container %s error: %v", containerInfo.Id, err)
+	}
+}
+
+// shouldRestart 手动停止的容器不再根据重启策略重启，因为不健康被杀死的容器总是重启
+func shouldRestart(containerInfo *container.Info) bool {
+	if containerInfo.Status == container.STOP {
+		return false
+	}
+	if containerInfo.HealthConfig != nil && containerInfo.HealthConfig.RestartOnUnhealthy &&
+		containerInfo.Health != nil && containerInfo.Health.Status == container.HealthUnhealthy {
+		return true
+	}
+	return containerInfo.RestartPolicy.ShouldRestart(containerInfo.ExitCode, containerInfo.RestartCount)
+}
+
+// waitInitProcess 等待并回收容器 init 进程，被信号杀死时退出码为 128+signal
+func waitInitProcess(parent *exec.Cmd) int {
+	_ = parent.Wait()
+	if parent.ProcessState == nil {
+		return -1
+	}
+	if status, ok := parent.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return parent.ProcessState.ExitCode()
+}
+
+// recordContainerExit 释放容器运行时占用的 cgroup 和网络端点，执行 poststop hook，并记录退出状态
+func recordContainerExit(containerId string, exitCode int) *container.Info {
+	// 重新读取容器信息，容器运行期间 stop 等命令可能已经修改过
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		logrus.Errorf("get container info error: %v", err)
+		return nil
+	}
+	oomKilled := releaseContainerResources(containerInfo)
+	// stop 会等待退出状态被记录，所以 poststop hook 执行完成之后 stop 才返回
+	if err = runContainerHooks(containerInfo, container.HookPoststop, "stopped"); err != nil {
+		logrus.Warnf("run poststop hooks of container %s error: %v", containerId, err)
+	}
+	return saveContainerExit(containerId, exitCode, oomKilled)
+}
+
+// saveContainerExit 记录容器的退出码、退出时间等信息
+func saveContainerExit(containerId string, exitCode int, oomKilled bool) *container.Info {
+	containerInfo, err := container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		info.ExitCode = exitCode
+		info.OOMKilled = oomKilled
+		markContainerExited(info)
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("update container info error: %v", err)
+		return nil
+	}
+	logContainerExit(containerInfo)
+	return containerInfo
+}
+
+// logContainerExit 记录容器退出的事件，被 OOM killer 杀死时先记录 oom 事件
+func logContainerExit(containerInfo *container.Info) {
+	if containerInfo.OOMKilled {
+		logContainerEvent(containerInfo, "oom")
+	}
+	logContainerEvent(containerInfo, "die", "exitCode", strconv.Itoa(containerInfo.ExitCode))
+}
+
+// releaseContainerResources 释放容器运行时占用的 cgroup 和网络端点，返回容器是否被 OOM killer 杀死
+func releaseContainerResources(containerInfo *container.Info) bool {
+	cgroupManager := cgroups.NewCgroupManager(getCgroupPath(containerInfo.Id), nil)
+	oomKilled := cgroupManager.OOMKilled()
+	cgroupManager.Destory()
+
+	if containerInfo.NetworkName != "" {
+		if err := network.Disconnect(containerInfo); err != nil {
+			logrus.Warnf("disconnect container %s network error: %v", containerInfo.Id, err)
+		}
+	}
+	return oomKilled
+}
+
+// markContainerExited 手动停止的容器保持 stopped 状态，其他情况转换为 exited
+func markContainerExited(containerInfo *container.Info) {
+	containerInfo.Pid = ""
+	containerInfo.PidStartTime = 0
+	containerInfo.Ports = nil
+	containerInfo.FinishedAt = time.Now().Format(container.TimeFormat)
+	if containerInfo.Status != container.STOP {
+		containerInfo.Status = container.Exit
+	}
+}
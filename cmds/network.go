@@ -0,0 +1,42 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wangstu/mydocker/network"
+)
+
+// ListNetworks 按名称顺序列出满足过滤条件的网络
+func ListNetworks(filters []string) error {
+	args, err := parseFilters(filters, "name", "driver", "label")
+	if err != nil {
+		return err
+	}
+	networks, err := network.GetNetworks()
+	if err != nil {
+		return fmt.Errorf("load networks error: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "NAME\tIpRange\tDriver\n")
+	for _, nw := range networks {
+		if !matchNetwork(nw, args) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			nw.Name,
+			nw.IPRange.String(),
+			nw.Driver,
+		)
+	}
+	return w.Flush()
+}
+
+func matchNetwork(nw *network.Network, filters filterArgs) bool {
+	return filters.match("name", func(v string) bool { return strings.Contains(nw.Name, v) }) &&
+		filters.match("driver", func(v string) bool { return nw.Driver == v }) &&
+		filters.matchLabels(nw.Labels)
+}
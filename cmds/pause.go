@@ -0,0 +1,58 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/cgroups"
+	"github.com/wangstu/mydocker/container"
+)
+
+// PauseContainer 通过 cgroup freezer 暂停容器中的所有进程
+func PauseContainer(containerId string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Status != container.RUNNING {
+		return fmt.Errorf("container %s is not running", containerId)
+	}
+
+	if err = cgroups.NewCgroupManager(getCgroupPath(containerId), nil).Freeze(); err != nil {
+		return fmt.Errorf("freeze container %s error: %w", containerId, err)
+	}
+	if _, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		info.Status = container.PAUSED
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update container info error: %w", err)
+	}
+	logContainerEvent(containerInfo, "pause")
+	logrus.Infof("container %s paused", containerId)
+	return nil
+}
+
+// UnpauseContainer 恢复被暂停的容器
+func UnpauseContainer(containerId string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not paused", containerId)
+	}
+
+	if err = cgroups.NewCgroupManager(getCgroupPath(containerId), nil).Thaw(); err != nil {
+		return fmt.Errorf("thaw container %s error: %w", containerId, err)
+	}
+	if _, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		info.Status = container.RUNNING
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update container info error: %w", err)
+	}
+	logContainerEvent(containerInfo, "unpause")
+	logrus.Infof("container %s unpaused", containerId)
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// Port 输出容器的端口映射，格式和 docker port 一致
+/*
+1）没有指定 port 时输出所有映射，比如 80/tcp -> 0.0.0.0:8080
+2）指定了 port 时只输出对应的宿主机地址，port 的格式为 port[/protocol]，默认为 tcp
+3）端口映射在连接网络时分配，没有运行的容器没有端口映射
+*/
+func Port(containerId, port string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if port == "" {
+		for _, binding := range containerInfo.Ports {
+			fmt.Fprintf(os.Stdout, "%s -> %s\n", binding.ContainerPortProto(), binding.HostAddr())
+		}
+		return nil
+	}
+
+	number, protocol, found := strings.Cut(port, "/")
+	if !found {
+		protocol = container.ProtocolTCP
+	}
+	containerPort, err := strconv.Atoi(number)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", port)
+	}
+	matched := false
+	for _, binding := range containerInfo.Ports {
+		if binding.ContainerPort == containerPort && binding.Protocol == strings.ToLower(protocol) {
+			fmt.Fprintln(os.Stdout, binding.HostAddr())
+			matched = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no public port '%d/%s' published for %s", containerPort, strings.ToLower(protocol), containerId)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/image"
+	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// PruneOptions prune 命令的参数
+type PruneOptions struct {
+	Filters []string
+	DryRun  bool
+}
+
+// pruneCandidate 一个可以被清理的对象
+type pruneCandidate struct {
+	name   string
+	size   int64
+	remove func() error
+}
+
+// pruner 收集某一类可以被清理的对象，ignored 中的容器视为已经被删除，dry run 时用来模拟前面步骤的结果
+type pruner struct {
+	title   string
+	collect func(filters filterArgs, ignored map[string]bool) ([]pruneCandidate, error)
+}
+
+var (
+	containerPruner = pruner{title: "Containers", collect: containerPruneCandidates}
+	networkPruner   = pruner{title: "Networks", collect: networkPruneCandidates}
+	imagePruner     = pruner{title: "Images", collect: imagePruneCandidates}
+	layerPruner     = pruner{title: "Orphaned Layers", collect: layerPruneCandidates}
+)
+
+// PruneContainers 删除所有已经退出的容器
+func PruneContainers(opts PruneOptions) error {
+	return runPruners(opts, containerPruner)
+}
+
+// PruneNetworks 删除没有被任何容器使用的网络
+func PruneNetworks(opts PruneOptions) error {
+	return runPruners(opts, networkPruner)
+}
+
+// PruneImages 删除没有被任何容器使用的镜像
+func PruneImages(opts PruneOptions) error {
+	return runPruners(opts, imagePruner)
+}
+
+// SystemPrune 依次清理退出的容器、无用的网络和镜像，以及容器记录已经不存在的 overlay 目录
+func SystemPrune(opts PruneOptions) error {
+	return runPruners(opts, containerPruner, networkPruner, imagePruner, layerPruner)
+}
+
+func runPruners(opts PruneOptions, pruners ...pruner) error {
+	filters, err := parseFilters(opts.Filters, "until", "label")
+	if err != nil {
+		return err
+	}
+	for _, value := range filters["until"] {
+		if _, err = parseTimestamp("until", value, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	// 前面的步骤删除的容器不再占用网络和镜像
+	ignored := map[string]bool{}
+	var reclaimed int64
+	failed := 0
+	for _, p := range pruners {
+		candidates, err := p.collect(filters, ignored)
+		if err != nil {
+			return fmt.Errorf("collect %s error: %w", strings.ToLower(p.title), err)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		if opts.DryRun {
+			fmt.Printf("Would delete %s:\n", p.title)
+		} else {
+			fmt.Printf("Deleted %s:\n", p.title)
+		}
+		for _, candidate := range candidates {
+			if !opts.DryRun {
+				if err = candidate.remove(); err != nil {
+					logrus.Errorf("remove %s error: %v", candidate.name, err)
+					failed++
+					continue
+				}
+			}
+			if p.title == containerPruner.title {
+				ignored[candidate.name] = true
+			}
+			fmt.Println(candidate.name)
+			reclaimed += candidate.size
+		}
+		fmt.Println()
+	}
+
+	if opts.DryRun {
+		fmt.Printf("Total reclaimable space: %s\n", utils.HumanSize(reclaimed))
+	} else {
+		fmt.Printf("Total reclaimed space: %s\n", utils.HumanSize(reclaimed))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d objects", failed)
+	}
+	return nil
+}
+
+func containerPruneCandidates(filters filterArgs, _ map[string]bool) ([]pruneCandidate, error) {
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []pruneCandidate
+	for _, info := range containerInfos {
+		// created 状态的容器可能正在被 run 启动，不做清理
+		// stop 状态的容器在 monitor 记录退出状态之前 pid 不为空，删除时 RemoveContainer 还会在锁内重新检查
+		if (info.Status != container.Exit && info.Status != container.STOP) || info.Pid != "" {
+			continue
+		}
+		if created, err := parseTime(info.CreateTime); err != nil || !filters.matchUntil(created) {
+			continue
+		}
+		if !filters.matchLabels(info.Labels) {
+			continue
+		}
+		containerId := info.Id
+		// 容器目录中还有日志文件
+		infoSize, _ := utils.DirSize(fmt.Sprintf(container.InfoLocFormat, containerId))
+		candidates = append(candidates, pruneCandidate{
+			name: containerId,
+			size: workspaceSize(containerId) + infoSize,
+			remove: func() error {
+				return RemoveContainer(containerId, false)
+			},
+		})
+	}
+	return candidates, nil
+}
+
+func networkPruneCandidates(filters filterArgs, ignored map[string]bool) ([]pruneCandidate, error) {
+	used, err := usedByContainers(ignored, func(info *container.Info) string { return info.NetworkName })
+	if err != nil {
+		return nil, err
+	}
+	networks, err := network.GetNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []pruneCandidate
+	for _, nw := range networks {
+		if used[nw.Name] {
+			continue
+		}
+		// 旧版本创建的网络没有记录创建时间，视为很早之前创建的
+		created, _ := parseTime(nw.CreateTime)
+		if !filters.matchUntil(created) || !filters.matchLabels(nw.Labels) {
+			continue
+		}
+		name := nw.Name
+		candidates = append(candidates, pruneCandidate{
+			name: name,
+			remove: func() error {
+				return network.DeleteNetwork(name)
+			},
+		})
+	}
+	return candidates, nil
+}
+
+func imagePruneCandidates(filters filterArgs, ignored map[string]bool) ([]pruneCandidate, error) {
+	used, err := usedByContainers(ignored, func(info *container.Info) string { return info.Image })
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(utils.ImagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read dir %s error: %w", utils.ImagePath, err)
+	}
+
+	var candidates []pruneCandidate
+	for _, entry := range entries {
+		imageName, ok := strings.CutSuffix(entry.Name(), ".tar")
+		if !ok || entry.IsDir() || used[imageName] {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil || !filters.matchUntil(stat.ModTime()) {
+			continue
+		}
+		config, err := image.ReadConfig(imageName)
+		if err != nil {
+			logrus.Warnf("skip image %s: %v", imageName, err)
+			continue
+		}
+		if !filters.matchLabels(config.Labels) {
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{
+			name: imageName,
+			size: stat.Size(),
+			remove: func() error {
+				return image.Remove(imageName)
+			},
+		})
+	}
+	return candidates, nil
+}
+
+// layerPruneCandidates 找到容器记录已经不存在的 overlay 目录，比如 rm 过程中进程被杀死留下的目录
+func layerPruneCandidates(filters filterArgs, ignored map[string]bool) ([]pruneCandidate, error) {
+	entries, err := os.ReadDir(utils.RootPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read dir %s error: %w", utils.RootPath, err)
+	}
+
+	var candidates []pruneCandidate
+	for _, entry := range entries {
+		containerId := entry.Name()
+		if !entry.IsDir() || ignored[containerId] {
+			continue
+		}
+		// 只要容器目录还在就不是孤儿目录，即使 config.json 已经损坏
+		exist, err := utils.IsPathExist(fmt.Sprintf(container.InfoLocFormat, containerId))
+		if err != nil || exist {
+			continue
+		}
+		// 孤儿目录没有 label，指定了 label 条件时不做清理
+		stat, err := entry.Info()
+		if err != nil || !filters.matchUntil(stat.ModTime()) || !filters.matchLabels(nil) {
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{
+			name: path.Join(utils.RootPath, containerId),
+			size: workspaceSize(containerId),
+			remove: func() error {
+				// 容器记录已经不存在，无法得知 volume 的挂载信息，先卸载目录中所有的挂载点
+				// 还有挂载点时不能删除，否则会通过 volume 的 bind mount 删除宿主机上的数据
+				if err := container.UmountAll(containerId); err != nil {
+					logrus.Warnf("skip removing layer %s: %v", containerId, err)
+					return err
+				}
+				container.DeleteWorkSpace(containerId, "")
+				return os.RemoveAll(utils.GetRootPath(containerId))
+			},
+		})
+	}
+	return candidates, nil
+}
+
+// usedByContainers 返回没有被忽略的容器所使用的网络或镜像
+func usedByContainers(ignored map[string]bool, key func(info *container.Info) string) (map[string]bool, error) {
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, info := range containerInfos {
+		if !ignored[info.Id] {
+			used[key(info)] = true
+		}
+	}
+	return used, nil
+}
+
+// workspaceSize 容器 overlay 目录占用的空间，merged 是挂载点，不重复统计
+func workspaceSize(containerId string) int64 {
+	var size int64
+	for _, dir := range []string{
+		utils.GetLowerPath(containerId),
+		utils.GetUpperPath(containerId),
+		utils.GetWorkPath(containerId),
+	} {
+		dirSize, err := utils.DirSize(dir)
+		if err != nil {
+			logrus.Warnf("get size of %s error: %v", dir, err)
+		}
+		size += dirSize
+	}
+	return size
+}
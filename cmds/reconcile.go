@@ -0,0 +1,82 @@
+package cmds
+
+import (
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// reconcileContainer 将持久化的容器状态和内核中的真实状态对齐，每次读取容器信息时调用
+/*
+宿主机重启或者 monitor 进程被杀死后，config.json 中记录的 pid 可能已经退出，甚至被其他进程复用。
+这里通过进程启动时间校验 pid 是否仍然是容器的 init 进程：
+1）init 进程仍然存活，不做处理
+2）init 进程已经退出，但 monitor 进程还活着，由 monitor 负责记录退出状态
+3）两者都不在了，由这里释放 cgroup、网络端点并将容器转换为 exited 状态，设置了 --rm 的容器直接删除
+*/
+func reconcileContainer(containerInfo *container.Info) {
+	if containerInfo.Pid == "" || !isContainerOrphaned(containerInfo) {
+		return
+	}
+	if !markOrphanedContainerExited(containerInfo) {
+		return
+	}
+	// hook 中可能会调用 mydocker 查询容器，不能在持有容器锁时执行
+	if err := runContainerHooks(containerInfo, container.HookPoststop, "stopped"); err != nil {
+		logrus.Warnf("run poststop hooks of container %s error: %v", containerInfo.Id, err)
+	}
+	autoRemoveContainer(containerInfo)
+}
+
+// markOrphanedContainerExited 释放没有进程看护的容器占用的资源并标记为 exited，返回是否由当前进程完成
+func markOrphanedContainerExited(containerInfo *container.Info) bool {
+	pid := containerInfo.Pid
+	// 多个命令可能同时发现同一个容器的进程已经不在了，加锁后重新检查，只由一个进程释放资源
+	lock, err := container.LockContainerInfo(containerInfo.Id)
+	if err != nil {
+		logrus.Errorf("lock container %s error: %v", containerInfo.Id, err)
+		return false
+	}
+	defer lock.Unlock()
+	latest, err := container.ReadContainerInfo(containerInfo.Id)
+	if err != nil {
+		logrus.Errorf("get container info error: %v", err)
+		return false
+	}
+	*containerInfo = *latest
+	if containerInfo.Pid != pid || !isContainerOrphaned(containerInfo) {
+		return false
+	}
+
+	logrus.Warnf("init process %s of container %s is gone, mark it exited", pid, containerInfo.Id)
+	return releaseUnrecordedExit(containerInfo)
+}
+
+// releaseUnrecordedExit 代替 monitor 释放容器占用的资源并记录退出状态，调用方需要持有容器锁，poststop hook 由调用方在释放锁之后执行
+func releaseUnrecordedExit(containerInfo *container.Info) bool {
+	containerInfo.OOMKilled = releaseContainerResources(containerInfo)
+	// 退出码已经无从得知
+	containerInfo.ExitCode = -1
+	markContainerExited(containerInfo)
+	if err := container.UpdateContainerInfo(containerInfo); err != nil {
+		logrus.Errorf("update container info error: %v", err)
+		return false
+	}
+	logContainerExit(containerInfo)
+	return true
+}
+
+// isContainerOrphaned init 进程和 monitor 进程都已经不在了
+func isContainerOrphaned(containerInfo *container.Info) bool {
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return false
+	}
+	if utils.IsSameProcess(pid, containerInfo.PidStartTime) {
+		return false
+	}
+	return containerInfo.MonitorPid == 0 || !utils.IsSameProcess(containerInfo.MonitorPid, containerInfo.MonitorStartTime)
+}
@@ -0,0 +1,63 @@
+package cmds
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/store"
+)
+
+// containerNamePattern 容器名称的格式和 docker 保持一致
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// RenameContainer 修改容器名称
+/*
+overlay 工作目录、cgroup、veth 设备和网络端点 ID 都是根据容器 ID 生成的，
+network inspect 等展示的容器名称也是读取时从容器信息中获取的，所以只需要修改 config.json 中的名称
+*/
+func RenameContainer(containerId, newName string) error {
+	if err := validateContainerName(newName); err != nil {
+		return err
+	}
+
+	// 名称唯一性检查和修改需要在全局锁内完成，避免和 run、rename 并发时出现同名的容器
+	lock, err := store.LockGlobal()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	id, err := getContainerIdByName(newName)
+	if err != nil {
+		return fmt.Errorf("check container name error: %w", err)
+	}
+	if id == containerId {
+		return fmt.Errorf("container %s is already named %s", containerId, newName)
+	}
+	if id != "" {
+		return fmt.Errorf("container name %s is already in use by container %s", newName, id)
+	}
+
+	var oldName string
+	containerInfo, err := container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		oldName = info.Name
+		info.Name = newName
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update container info error: %w", err)
+	}
+	logContainerEvent(containerInfo, "rename", "oldName", oldName)
+	logrus.Infof("container %s renamed from %s to %s", containerId, oldName, newName)
+	return nil
+}
+
+func validateContainerName(name string) error {
+	if !containerNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// ResolveContainerId 根据完整 ID、容器名称或者唯一的 ID 前缀找到容器 ID
+/*
+匹配顺序和 docker 保持一致：
+1）完整 ID
+2）容器名称
+3）ID 前缀，匹配到多个容器时报错
+*/
+func ResolveContainerId(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("empty container id or name")
+	}
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return "", err
+	}
+
+	for _, info := range containerInfos {
+		if info.Id == ref {
+			return info.Id, nil
+		}
+	}
+	for _, info := range containerInfos {
+		if info.Name == ref {
+			return info.Id, nil
+		}
+	}
+
+	var matched []string
+	for _, info := range containerInfos {
+		if strings.HasPrefix(info.Id, ref) {
+			matched = append(matched, info.Id)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("no such container: %s", ref)
+	case 1:
+		return matched[0], nil
+	default:
+		return "", fmt.Errorf("multiple containers found with id prefix %s: %s", ref, strings.Join(matched, ", "))
+	}
+}
+
+// getContainerIdByName 返回使用该名称的容器 ID，没有则返回空字符串
+func getContainerIdByName(name string) (string, error) {
+	containerInfos, err := listContainerInfos()
+	if err != nil {
+		return "", err
+	}
+	for _, info := range containerInfos {
+		if info.Name == name {
+			return info.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// listContainerInfos 读取 InfoLoc 下所有容器的信息，读取失败的容器会被跳过
+func listContainerInfos() ([]*container.Info, error) {
+	entries, err := os.ReadDir(container.InfoLoc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read dir %s error: %w", container.InfoLoc, err)
+	}
+
+	containerInfos := make([]*container.Info, 0, len(entries))
+	for _, entry := range entries {
+		tmpInfo, err := getContainerInfo(entry)
+		if err != nil {
+			logrus.Errorf("get container info error: %v", err)
+			continue
+		}
+		containerInfos = append(containerInfos, tmpInfo)
+	}
+	return containerInfos, nil
+}
@@ -1,40 +1,76 @@
 package cmds
 
 import (
+	"fmt"
+
 	"github.com/sirupsen/logrus"
+	"github.com/wangstu/mydocker/cgroups"
 	"github.com/wangstu/mydocker/container"
 	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
 )
 
-func RemoveContainer(containerId string, force bool) {
+func RemoveContainer(containerId string, force bool) error {
 	containerInfo, err := getInfoByContainerId(containerId)
 	if err != nil {
-		logrus.Errorf("get container info error: %v", err)
-		return
+		return fmt.Errorf("get container info error: %w", err)
 	}
 
 	switch containerInfo.Status {
-	case container.STOP:
-		if err = container.DeleteContainerInfo(containerId); err != nil {
-			logrus.Errorf("remove container %s config error: %v", containerId, err)
-			return
-		}
-		container.DeleteWorkSpace(containerId, containerInfo.Volume)
-		if containerInfo.NetworkName != "" {
-			if err = network.Disconnect(containerInfo); err != nil {
-				logrus.Errorf("remove container %s's network config error: %v", containerId, err)
-				return
-			}
-		}
-	case container.RUNNING:
+	case container.STOP, container.Exit, container.CREATED:
+		return removeStoppedContainer(containerId)
+	case container.RUNNING, container.PAUSED, container.RESTARTING:
 		if !force {
-			logrus.Errorf("can't remove running container %s, please stop container before attempting removal or force to remove", containerId)
-			return
+			return fmt.Errorf("can't remove running container %s, please stop container before attempting removal or force to remove", containerId)
 		}
 		logrus.Infof("force to delete container: %s", containerId)
-		StopContainer(containerId)
-		RemoveContainer(containerId, force)
+		if err = StopContainer(containerId, 0); err != nil {
+			return fmt.Errorf("stop container %s error: %w", containerId, err)
+		}
+		return RemoveContainer(containerId, force)
 	default:
-		logrus.Errorf("container %s is in invalid status: %s", containerId, containerInfo.Status)
+		return fmt.Errorf("container %s is in invalid status: %s", containerId, containerInfo.Status)
+	}
+}
+
+// removeStoppedContainer 在容器锁内重新检查状态后删除容器
+/*
+stop 状态的容器在 monitor 记录退出状态之前 pid 不为空，created 状态的容器可能正在被 monitor 启动，
+这时删除 overlay 目录和 IP 会影响仍在运行的 init 进程或 monitor 进程
+*/
+func removeStoppedContainer(containerId string) error {
+	lock, err := container.LockContainerInfo(containerId)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	containerInfo, err := container.ReadContainerInfo(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Pid != "" {
+		return fmt.Errorf("container %s is stopping, try again later", containerId)
+	}
+	if isContainerStarting(containerInfo) {
+		return fmt.Errorf("container %s is starting, try again later", containerId)
+	}
+
+	if err = container.DeleteContainerInfo(containerId); err != nil {
+		return fmt.Errorf("remove container %s config error: %w", containerId, err)
 	}
+	container.DeleteWorkSpace(containerId, containerInfo.Volume)
+	cgroups.NewCgroupManager(getCgroupPath(containerId), nil).Destory()
+	if containerInfo.NetworkName != "" {
+		if err = network.ReleaseIP(containerInfo); err != nil {
+			return fmt.Errorf("release container %s's ip error: %w", containerId, err)
+		}
+	}
+	logContainerEvent(containerInfo, "rm")
+	return nil
+}
+
+// isContainerStarting created 状态的容器仍有 monitor 进程在启动它
+func isContainerStarting(containerInfo *container.Info) bool {
+	return containerInfo.Status == container.CREATED && containerInfo.MonitorPid != 0 &&
+		utils.IsSameProcess(containerInfo.MonitorPid, containerInfo.MonitorStartTime)
 }
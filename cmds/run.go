@@ -1,72 +1,248 @@
 package cmds
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/wangstu/mydocker/cgroups"
 	"github.com/wangstu/mydocker/cgroups/subsystems"
 	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/image"
 	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/store"
+	"github.com/wangstu/mydocker/utils"
 )
 
-func Run(tty bool, cmds, envSlice []string, res *subsystems.ResourceConfig,
-	volume, containerName, imageName, networkName string, portMapping []string) {
-	containerId := container.GenerateContainerID()
+// RunOptions run 命令的参数
+type RunOptions struct {
+	Tty           bool
+	Cmds          []string
+	Env           []string
+	Resource      *subsystems.ResourceConfig
+	Volume        string
+	Name          string
+	Image         string
+	Network       string
+	PortMapping   []string
+	RestartPolicy container.RestartPolicy
+	StopSignal    string
+	AutoRemove    bool
+	HealthConfig  *container.HealthConfig
+	Hooks         *container.Hooks
+	Labels        map[string]string
+}
 
-	parent, writePipe := container.NewParentProcess(tty, volume, containerId, imageName, envSlice)
-	if parent == nil {
-		logrus.Errorf("New Parent process error")
+func Run(opts RunOptions) {
+	// 和 docker 一样，容器继承镜像的 label，同名时以 --label 为准
+	imageConfig, err := image.ReadConfig(opts.Image)
+	if err != nil {
+		logrus.Errorf("run container error: %v", err)
 		return
 	}
+	// 没有指定命令时使用镜像的默认命令，镜像的环境变量放在前面，同名时以 -e 为准
+	if len(opts.Cmds) == 0 {
+		opts.Cmds = imageConfig.Cmd
+	}
+	if len(opts.Cmds) == 0 {
+		logrus.Errorf("run container error: no command specified")
+		return
+	}
+	env := append(append([]string{}, imageConfig.Env...), opts.Env...)
+	containerInfo := &container.Info{
+		Id:             container.GenerateContainerID(),
+		Name:           opts.Name,
+		Command:        strings.Join(opts.Cmds, " "),
+		Volume:         opts.Volume,
+		NetworkName:    opts.Network,
+		PortMapping:    opts.PortMapping,
+		Image:          opts.Image,
+		Env:            env,
+		ResourceConfig: opts.Resource,
+		RestartPolicy:  opts.RestartPolicy,
+		StopSignal:     opts.StopSignal,
+		AutoRemove:     opts.AutoRemove,
+		HealthConfig:   opts.HealthConfig,
+		Hooks:          opts.Hooks,
+		Labels:         utils.MergeLabels(imageConfig.Labels, opts.Labels),
+	}
 
-	if err := parent.Start(); err != nil {
-		logrus.Errorf("Run parent.Start error: %v", err)
+	containerInfo.Status = container.CREATED
+	if err = createContainer(containerInfo); err != nil {
+		logrus.Errorf("create container error: %v", err)
+		return
+	}
+
+	if !opts.Tty {
+		// 后台容器交给 monitor 进程启动和看护
+		if err = startMonitor(containerInfo.Id); err != nil {
+			logrus.Errorf("run container error: %v", err)
+		}
 		return
 	}
 
-	cgroupManager := cgroups.NewCgroupManager("mydocker", res)
-	defer cgroupManager.Destory()
+	// 前台容器由当前进程看护，和 monitor 进程一样记录退出状态
+	setMonitorProcess(containerInfo)
+	parent, err := launchContainer(true, containerInfo)
+	if err != nil {
+		logrus.Errorf("run container error: %v", err)
+		// 容器记录删除之后就没有机会再清理 overlay 工作目录，这里一起删除
+		container.DeleteWorkSpace(containerInfo.Id, containerInfo.Volume)
+		container.DeleteContainerInfo(containerInfo.Id)
+		return
+	}
+
+	stopHealthCheck := startHealthCheck(containerInfo.Id, containerInfo.HealthConfig)
+	exitCode := waitInitProcess(parent)
+	stopHealthCheck()
+	autoRemoveContainer(recordContainerExit(containerInfo.Id, exitCode))
+}
+
+// createContainer 在全局锁内检查容器名称是否可用并记录容器信息，避免并发创建出同名的容器
+func createContainer(containerInfo *container.Info) error {
+	lock, err := store.LockGlobal()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if containerInfo.Name != "" {
+		if err = validateContainerName(containerInfo.Name); err != nil {
+			return err
+		}
+		id, err := getContainerIdByName(containerInfo.Name)
+		if err != nil {
+			return fmt.Errorf("check container name error: %w", err)
+		}
+		if id != "" {
+			return fmt.Errorf("container name %s is already in use by container %s", containerInfo.Name, id)
+		}
+	}
+	if err = container.RecordContainerInfo(containerInfo); err != nil {
+		return err
+	}
+	logContainerEvent(containerInfo, "create")
+	return nil
+}
+
+// launchContainer 根据容器信息启动容器的 init 进程，run 和 start 共用这个流程
+/*
+1）准备 overlay 工作目录并启动 init 进程
+2）设置 cgroup 资源限制
+3）配置容器网络
+4）记录容器信息，最后通过管道把用户命令发送给 init 进程
+*/
+func launchContainer(tty bool, containerInfo *container.Info) (*exec.Cmd, error) {
+	// 先记录正在启动容器的进程，rm 据此判断 created 状态的容器是否正在启动
+	if _, err := container.ModifyContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		info.MonitorPid = containerInfo.MonitorPid
+		info.MonitorStartTime = containerInfo.MonitorStartTime
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("record monitor process error: %w", err)
+	}
+	parent, writePipe := container.NewParentProcess(tty, containerInfo.Volume, containerInfo.Id,
+		containerInfo.Image, containerInfo.Env)
+	if parent == nil {
+		return nil, fmt.Errorf("new parent process error")
+	}
+	// init 进程读不到命令就会直接退出，出错时关闭管道即可
+	defer writePipe.Close()
+
+	if err := parent.Start(); err != nil {
+		return nil, fmt.Errorf("start parent process error: %w", err)
+	}
+	containerInfo.Pid = strconv.Itoa(parent.Process.Pid)
+	if startTime, err := utils.GetProcessStartTime(parent.Process.Pid); err == nil {
+		containerInfo.PidStartTime = startTime
+	}
+
+	res := containerInfo.ResourceConfig
+	if res == nil {
+		res = &subsystems.ResourceConfig{}
+	}
+	cgroupManager := cgroups.NewCgroupManager(getCgroupPath(containerInfo.Id), res)
 	_ = cgroupManager.Set()
 	_ = cgroupManager.Apply(parent.Process.Pid)
 
-	var containerIP string
-	if networkName != "" {
+	if containerInfo.NetworkName != "" {
 		// config container network
-		containerInfo := &container.Info{
-			Id:          containerId,
-			Pid:         strconv.Itoa(parent.Process.Pid),
-			Name:        containerName,
-			PortMapping: portMapping,
+		ip, err := network.Connect(containerInfo.NetworkName, containerInfo)
+		if err != nil {
+			abortLaunch(parent, containerInfo)
+			return nil, fmt.Errorf("connect network error: %w", err)
 		}
-		if ip, err := network.Connect(networkName, containerInfo); err != nil {
-			logrus.Errorf("connect network error: %v", err)
-			return
-		} else {
-			containerIP = ip.String()
-			logrus.Infof("configured network, ip: %v", ip)
+		containerInfo.IP = ip.String()
+		logrus.Infof("configured network, ip: %v", ip)
+	}
+
+	for _, stage := range []string{container.HookCreateRuntime, container.HookPrestart} {
+		if err := runContainerHooks(containerInfo, stage, "created"); err != nil {
+			abortLaunch(parent, containerInfo)
+			return nil, err
 		}
 	}
 
-	containerInfo, err := container.RecordContainerInfo(parent.Process.Pid, cmds,
-		containerName, containerId, volume, networkName, containerIP, portMapping)
+	// 连接网络和执行 hook 期间容器可能被重命名、更新或者停止，只修改启动相关的字段，不能用内存中的旧信息覆盖
+	latest, err := container.ModifyContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		if info.Status == container.STOP {
+			return fmt.Errorf("container %s is stopped while starting", info.Id)
+		}
+		info.Pid = containerInfo.Pid
+		info.PidStartTime = containerInfo.PidStartTime
+		info.MonitorPid = containerInfo.MonitorPid
+		info.MonitorStartTime = containerInfo.MonitorStartTime
+		info.IP = containerInfo.IP
+		info.Ports = containerInfo.Ports
+		info.Status = container.RUNNING
+		info.StartedAt = time.Now().Format(container.TimeFormat)
+		if info.HealthConfig != nil {
+			info.Health = &container.Health{Status: container.HealthStarting}
+		}
+		return nil
+	})
 	if err != nil {
-		logrus.Errorf("record container info error: %v", err)
-		return
+		abortLaunch(parent, containerInfo)
+		return nil, fmt.Errorf("record container info error: %w", err)
 	}
+	*containerInfo = *latest
 
-	sendInitCommands(writePipe, cmds)
-	if tty {
-		_ = parent.Wait()
-		container.DeleteWorkSpace(containerId, volume)
-		container.DeleteContainerInfo(containerId)
-		if networkName != "" {
-			network.Disconnect(containerInfo)
-		}
+	logContainerEvent(containerInfo, "start")
+	sendInitCommands(writePipe, strings.Split(containerInfo.Command, " "))
+	if err := runContainerHooks(containerInfo, container.HookPoststart, "running"); err != nil {
+		logrus.Warnf("run poststart hooks of container %s error: %v", containerInfo.Id, err)
 	}
+	return parent, nil
+}
+
+// abortLaunch 启动过程中出错时杀死已经创建的 init 进程，释放 cgroup 和网络端点并执行 poststop hook
+func abortLaunch(parent *exec.Cmd, containerInfo *container.Info) {
+	_ = parent.Process.Kill()
+	_ = parent.Wait()
+	releaseContainerResources(containerInfo)
+	if err := runContainerHooks(containerInfo, container.HookPoststop, "stopped"); err != nil {
+		logrus.Warnf("run poststop hooks of container %s error: %v", containerInfo.Id, err)
+	}
+	containerInfo.Pid = ""
+	containerInfo.PidStartTime = 0
+	containerInfo.Ports = nil
+	// 连接网络时端口已经写入容器信息，需要清除，否则其他容器无法再使用这些端口
+	if _, err := container.ModifyContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		info.Ports = nil
+		return nil
+	}); err != nil {
+		logrus.Warnf("clear ports of container %s error: %v", containerInfo.Id, err)
+	}
+}
+
+// getCgroupPath 每个容器使用独立的 cgroup，便于 stop、start 时重新设置
+func getCgroupPath(containerId string) string {
+	return "mydocker-" + containerId
 }
 
 func sendInitCommands(writePipe *os.File, cmds []string) {
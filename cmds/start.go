@@ -0,0 +1,46 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// StartContainer 在已有的 overlay 工作目录上重新启动一个已停止的容器
+func StartContainer(containerId string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Status == container.RUNNING {
+		return fmt.Errorf("container %s is already running", containerId)
+	}
+	// pid 在 monitor 记录退出状态后才会清空
+	if containerInfo.Pid != "" {
+		return fmt.Errorf("container %s is still stopping", containerId)
+	}
+
+	// 手动启动时重置重启次数，启动期间为 created 状态，启动完成前被 stop 时 launchContainer 会放弃启动
+	if _, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		info.RestartCount = 0
+		info.Status = container.CREATED
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update container info error: %w", err)
+	}
+	if err = startMonitor(containerId); err != nil {
+		return fmt.Errorf("start container %s error: %w", containerId, err)
+	}
+	logrus.Infof("container %s started", containerId)
+	return nil
+}
+
+// RestartContainer 先停止容器，等待 timeout 秒后仍未退出则发送 SIGKILL，然后重新启动
+func RestartContainer(containerId string, timeout int) error {
+	if err := StopContainer(containerId, timeout); err != nil {
+		return err
+	}
+	return StartContainer(containerId)
+}
@@ -0,0 +1,222 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/cgroups"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/network"
+	"github.com/wangstu/mydocker/utils"
+)
+
+const statsInterval = time.Second
+
+// StatsOptions stats 命令的参数，Containers 为空时显示所有运行中的容器
+type StatsOptions struct {
+	Containers []string
+	NoStream   bool
+	Format     string
+}
+
+// containerStats stats 输出的一行，--format 中的模板以及 json 格式都基于这个结构
+// CPUUsage、ThrottledTime 的单位为纳秒，其他大小的单位都为字节
+type containerStats struct {
+	ID               string  `json:"ID"`
+	Name             string  `json:"Name"`
+	CPUPercent       float64 `json:"CPUPercent"`
+	CPUUsage         uint64  `json:"CPUUsage"`
+	ThrottledPeriods uint64  `json:"ThrottledPeriods"`
+	ThrottledTime    uint64  `json:"ThrottledTime"`
+	MemUsage         uint64  `json:"MemUsage"`
+	MemLimit         uint64  `json:"MemLimit"`
+	MemPercent       float64 `json:"MemPercent"`
+	NetRx            uint64  `json:"NetRx"`
+	NetTx            uint64  `json:"NetTx"`
+	BlockRead        uint64  `json:"BlockRead"`
+	BlockWrite       uint64  `json:"BlockWrite"`
+	Pids             uint64  `json:"Pids"`
+	PidsLimit        uint64  `json:"PidsLimit"`
+
+	read time.Time
+}
+
+// Stats 输出容器的资源使用情况
+/*
+1）CPU 使用率需要两次采样，所以第一次输出在启动 1 秒之后
+2）--no-stream 时只输出一次，否则每秒刷新一次，没有指定容器时每次刷新都重新获取运行中的容器
+*/
+func Stats(opts StatsOptions) error {
+	var tmpl *template.Template
+	if opts.Format != "" && opts.Format != FormatTable && opts.Format != FormatJson {
+		var err error
+		if tmpl, err = template.New("stats").Parse(opts.Format); err != nil {
+			return fmt.Errorf("parse format error: %w", err)
+		}
+	}
+
+	previous := map[string]*containerStats{}
+	for round := 0; ; round++ {
+		infos, err := getStatsTargets(opts.Containers)
+		if err != nil {
+			return err
+		}
+		current := make([]*containerStats, 0, len(infos))
+		for _, info := range infos {
+			stats := getContainerStats(info)
+			if prev, ok := previous[stats.ID]; ok {
+				stats.CPUPercent = cpuPercent(prev, stats)
+			}
+			current = append(current, stats)
+		}
+		previous = make(map[string]*containerStats, len(current))
+		for _, stats := range current {
+			previous[stats.ID] = stats
+		}
+
+		if round > 0 {
+			if err = printStats(current, opts, tmpl); err != nil {
+				return err
+			}
+			if opts.NoStream {
+				return nil
+			}
+		}
+		time.Sleep(statsInterval)
+	}
+}
+
+// getStatsTargets 返回指定的容器，没有指定时返回所有运行中的容器
+func getStatsTargets(containerIds []string) ([]*container.Info, error) {
+	if len(containerIds) == 0 {
+		containerInfos, err := listContainerInfos()
+		if err != nil {
+			return nil, fmt.Errorf("list containers error: %w", err)
+		}
+		var result []*container.Info
+		for _, info := range containerInfos {
+			if info.Status == container.RUNNING || info.Status == container.PAUSED {
+				result = append(result, info)
+			}
+		}
+		return result, nil
+	}
+
+	result := make([]*container.Info, 0, len(containerIds))
+	for _, containerId := range containerIds {
+		info, err := getInfoByContainerId(containerId)
+		if err != nil {
+			return nil, fmt.Errorf("get container info error: %w", err)
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// getContainerStats 读取容器 cgroup 和网卡的计数，没有运行的容器所有计数都为 0
+func getContainerStats(info *container.Info) *containerStats {
+	stats := &containerStats{ID: info.Id, Name: info.Name, read: time.Now()}
+	if info.Status != container.RUNNING && info.Status != container.PAUSED {
+		return stats
+	}
+
+	cgroupStats, err := cgroups.NewCgroupManager(getCgroupPath(info.Id), nil).GetStats()
+	if err != nil {
+		logrus.Warnf("get stats of container %s error: %v", info.Id, err)
+		return stats
+	}
+	stats.CPUUsage = cgroupStats.Cpu.Usage
+	stats.ThrottledPeriods = cgroupStats.Cpu.ThrottledPeriods
+	stats.ThrottledTime = cgroupStats.Cpu.ThrottledTime
+	stats.MemUsage = cgroupStats.Memory.Usage
+	stats.MemLimit = cgroupStats.Memory.Limit
+	// 没有内存限制时 limit 是一个很大的数，和 docker 一样使用宿主机的内存大小
+	if hostMemory := getHostMemory(); hostMemory > 0 && (stats.MemLimit == 0 || stats.MemLimit > hostMemory) {
+		stats.MemLimit = hostMemory
+	}
+	if stats.MemLimit > 0 {
+		stats.MemPercent = float64(stats.MemUsage) / float64(stats.MemLimit) * 100
+	}
+	stats.BlockRead = cgroupStats.Blkio.Read
+	stats.BlockWrite = cgroupStats.Blkio.Write
+	stats.Pids = cgroupStats.Pids.Current
+	stats.PidsLimit = cgroupStats.Pids.Limit
+
+	pid, _ := strconv.Atoi(info.Pid)
+	netStats, err := network.GetContainerStats(pid)
+	if err != nil {
+		logrus.Warnf("get network stats of container %s error: %v", info.Id, err)
+		return stats
+	}
+	stats.NetRx = netStats.RxBytes
+	stats.NetTx = netStats.TxBytes
+	return stats
+}
+
+// cpuPercent 两次采样之间容器消耗的 CPU 时间占经过时间的比例，100% 表示占满一个核
+func cpuPercent(prev, current *containerStats) float64 {
+	elapsed := current.read.Sub(prev.read)
+	if elapsed <= 0 || current.CPUUsage < prev.CPUUsage {
+		return 0
+	}
+	return float64(current.CPUUsage-prev.CPUUsage) / float64(elapsed.Nanoseconds()) * 100
+}
+
+func getHostMemory() uint64 {
+	info := &syscall.Sysinfo_t{}
+	if err := syscall.Sysinfo(info); err != nil {
+		return 0
+	}
+	return info.Totalram * uint64(info.Unit)
+}
+
+func printStats(rows []*containerStats, opts StatsOptions, tmpl *template.Template) error {
+	switch {
+	case opts.Format == FormatJson:
+		for _, row := range rows {
+			fmt.Fprintln(os.Stdout, utils.Marshal(row))
+		}
+	case tmpl != nil:
+		for _, row := range rows {
+			if err := tmpl.Execute(os.Stdout, row); err != nil {
+				return fmt.Errorf("execute format error: %w", err)
+			}
+			fmt.Fprintln(os.Stdout)
+		}
+	default:
+		if !opts.NoStream {
+			// 清屏并把光标移动到左上角，刷新整个表格
+			fmt.Fprint(os.Stdout, "\033[2J\033[H")
+		}
+		printStatsTable(rows)
+	}
+	return nil
+}
+
+func printStatsTable(rows []*containerStats) {
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "CONTAINER ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			row.ID,
+			row.Name,
+			row.CPUPercent,
+			utils.HumanSize(int64(row.MemUsage)),
+			utils.HumanSize(int64(row.MemLimit)),
+			row.MemPercent,
+			utils.HumanSize(int64(row.NetRx)),
+			utils.HumanSize(int64(row.NetTx)),
+			utils.HumanSize(int64(row.BlockRead)),
+			utils.HumanSize(int64(row.BlockWrite)),
+			row.Pids,
+		)
+	}
+	w.Flush()
+}
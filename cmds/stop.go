@@ -1,65 +1,158 @@
 package cmds
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/cgroups"
 	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
 )
 
-func StopContainer(containerId string) {
+const (
+	DefaultStopTimeout = 10
+	waitInterval       = 100 * time.Millisecond
+)
+
+var errNotRunning = errors.New("container is not running")
+
+// StopContainer 发送容器的 stop signal，timeout 秒后进程仍未退出则发送 SIGKILL
+func StopContainer(containerId string, timeout int) error {
 	// get container info
 	containerInfo, err := getInfoByContainerId(containerId)
 	if err != nil {
-		logrus.Errorf("get container info error: %v", err)
-		return
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	stopSignal := syscall.SIGTERM
+	if containerInfo.StopSignal != "" {
+		if stopSignal, err = utils.ParseSignal(containerInfo.StopSignal); err != nil {
+			return err
+		}
+	}
+
+	// mark container stopped before sending signal, so that monitor keeps the status when container exits,
+	// a container waiting to be restarted by monitor is just marked stopped, which disables the restart policy
+	var paused bool
+	containerInfo, err = container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		if info.Status != container.RESTARTING && info.Pid == "" {
+			return errNotRunning
+		}
+		paused = info.Status == container.PAUSED
+		info.Status = container.STOP
+		return nil
+	})
+	if errors.Is(err, errNotRunning) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("update container info error: %w", err)
+	}
+	if containerInfo.Pid == "" {
+		logContainerEvent(containerInfo, "stop")
+		return nil
 	}
 	pidInt, err := strconv.Atoi(containerInfo.Pid)
 	if err != nil {
-		logrus.Errorf("convert pid error: %v", err)
-		return
+		return fmt.Errorf("convert pid error: %w", err)
 	}
 
-	// send SIGTERM to container
-	if err = syscall.Kill(pidInt, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
-		logrus.Errorf("stop container %s error: %v", containerId, err)
-		return
+	// send stop signal to container
+	if err = syscall.Kill(pidInt, stopSignal); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("stop container %s error: %w", containerId, err)
 	}
+	logContainerEvent(containerInfo, "kill", "signal", strconv.Itoa(int(stopSignal)))
 
-	// modify container info
-	containerInfo.Pid = ""
-	containerInfo.Status = container.STOP
-	newContentBytes, err := json.MarshalIndent(containerInfo, "", "  ")
-	if err != nil {
-		logrus.Errorf("marshal json info error: %v", err)
-		return
+	// frozen processes can not handle the signal until they are thawed
+	if paused {
+		if err = cgroups.NewCgroupManager(getCgroupPath(containerId), nil).Thaw(); err != nil {
+			logrus.Errorf("thaw container %s error: %v", containerId, err)
+		}
 	}
 
-	// save container info
-	folder := fmt.Sprintf(container.InfoLocFormat, containerId)
-	infoFilePath := path.Join(folder, container.ConfigName)
-	if err = os.WriteFile(infoFilePath, newContentBytes, constant.Perm0644); err != nil {
-		logrus.Errorf("write container info %s error: %v", infoFilePath, err)
+	if !waitProcessExit(pidInt, time.Duration(timeout)*time.Second) {
+		logrus.Warnf("container %s did not exit in %ds, kill it", containerId, timeout)
+		if err = syscall.Kill(pidInt, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("kill container %s error: %w", containerId, err)
+		}
+		logContainerEvent(containerInfo, "kill", "signal", strconv.Itoa(int(syscall.SIGKILL)))
+		waitProcessExit(pidInt, DefaultStopTimeout*time.Second)
 	}
+
+	// wait for monitor to record the exit status and release network endpoint,
+	// monitor runs poststop hooks before recording, so wait at least as long as the hooks may take
+	if !waitContainerExit(containerId, DefaultStopTimeout*time.Second+getHooksTimeout(containerInfo, container.HookPoststop)) {
+		// nobody records the exit of the container, e.g. monitor was killed
+		logrus.Warnf("exit of container %s is not recorded, release its resources", containerId)
+		if markUnrecordedContainerExited(containerInfo) {
+			if err = runContainerHooks(containerInfo, container.HookPoststop, "stopped"); err != nil {
+				logrus.Warnf("run poststop hooks of container %s error: %v", containerId, err)
+			}
+			defer autoRemoveContainer(containerInfo)
+		}
+	}
+	logContainerEvent(containerInfo, "stop")
+	return nil
 }
 
-func getInfoByContainerId(containerId string) (*container.Info, error) {
-	folder := fmt.Sprintf(container.InfoLocFormat, containerId)
-	infoFilePath := path.Join(folder, container.ConfigName)
-	contentBytes, err := os.ReadFile(infoFilePath)
+// markUnrecordedContainerExited 和 reconcile 处理孤儿容器一样释放资源并记录退出状态，退出状态已经被记录时返回 false
+func markUnrecordedContainerExited(containerInfo *container.Info) bool {
+	pid := containerInfo.Pid
+	lock, err := container.LockContainerInfo(containerInfo.Id)
 	if err != nil {
-		return nil, fmt.Errorf("read info error: %w", err)
+		logrus.Errorf("lock container %s error: %v", containerInfo.Id, err)
+		return false
 	}
-	containerInfo := &container.Info{}
-	if err = json.Unmarshal(contentBytes, containerInfo); err != nil {
-		return nil, fmt.Errorf("unmarshal info error: %w", err)
+	defer lock.Unlock()
+	latest, err := container.ReadContainerInfo(containerInfo.Id)
+	if err != nil {
+		logrus.Errorf("get container info error: %v", err)
+		return false
+	}
+	*containerInfo = *latest
+	if containerInfo.Pid != pid {
+		return false
+	}
+	return releaseUnrecordedExit(containerInfo)
+}
+
+func getInfoByContainerId(containerId string) (*container.Info, error) {
+	containerInfo, err := container.ReadContainerInfo(containerId)
+	if err != nil {
+		return nil, err
 	}
+	reconcileContainer(containerInfo)
 	return containerInfo, nil
 }
+
+// waitContainerExit 轮询容器信息，直到 monitor 进程记录了退出状态
+func waitContainerExit(containerId string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		containerInfo, err := getInfoByContainerId(containerId)
+		if err != nil || containerInfo.Pid == "" {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+// waitProcessExit 轮询等待进程退出，超时返回 false
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !utils.IsProcessAlive(pid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(waitInterval)
+	}
+}
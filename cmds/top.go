@@ -0,0 +1,164 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wangstu/mydocker/cgroups"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// Top 列出容器中的所有进程
+/*
+1）进程列表来自容器的 freezer cgroup，也就是 init 进程和它创建的所有子进程
+2）没有指定 ps 参数时直接读取 /proc，输出用户、宿主机和容器中的 pid、CPU 时间、RSS 和命令行
+3）指定了 ps 参数时和 docker 一样，在宿主机上执行 ps，只保留容器中的进程
+*/
+func Top(containerId string, psArgs []string) error {
+	containerInfo, err := getInfoByContainerId(containerId)
+	if err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running", containerId)
+	}
+	pids, err := cgroups.NewCgroupManager(getCgroupPath(containerId), nil).GetPids()
+	if err != nil {
+		return fmt.Errorf("get processes of container %s error: %w", containerId, err)
+	}
+
+	if len(psArgs) > 0 {
+		return psTop(pids, psArgs)
+	}
+	processes := getContainerProcesses(pids)
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+	fmt.Fprint(w, "USER\tPID\tNSPID\tPPID\tTIME\tRSS\tCMD\n")
+	users := map[int]string{}
+	for _, p := range processes {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			lookupUser(users, p.Uid),
+			p.Pid,
+			p.NSPid,
+			p.PPid,
+			formatCPUTime(p.cpuTime),
+			utils.HumanSize(p.RSS),
+			p.cmdline,
+		)
+	}
+	return w.Flush()
+}
+
+type containerProcess struct {
+	*utils.ProcessStatus
+	cpuTime time.Duration
+	cmdline string
+}
+
+// getContainerProcesses 按 pid 排序返回进程信息，读取过程中已经退出的进程直接跳过
+func getContainerProcesses(pids []int) []*containerProcess {
+	seen := map[int]bool{}
+	var processes []*containerProcess
+	for _, pid := range pids {
+		status, err := utils.GetProcessStatus(pid)
+		if err != nil {
+			continue
+		}
+		// tasks 中的线程归到所属的进程
+		if status.Tgid != 0 && status.Tgid != status.Pid {
+			if status, err = utils.GetProcessStatus(status.Tgid); err != nil {
+				continue
+			}
+		}
+		if seen[status.Pid] {
+			continue
+		}
+		seen[status.Pid] = true
+
+		cpuTime, err := utils.GetProcessCPUTime(status.Pid)
+		if err != nil {
+			continue
+		}
+		cmdline, err := utils.GetProcessCmdline(status.Pid)
+		if err != nil {
+			continue
+		}
+		if cmdline == "" {
+			cmdline = "[" + status.Name + "]"
+		}
+		processes = append(processes, &containerProcess{ProcessStatus: status, cpuTime: cpuTime, cmdline: cmdline})
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].Pid < processes[j].Pid
+	})
+	return processes
+}
+
+// lookupUser 使用宿主机上的用户名，和 docker top 一致，找不到时输出 uid
+func lookupUser(cache map[int]string, uid int) string {
+	if name, ok := cache[uid]; ok {
+		return name
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	cache[uid] = name
+	return name
+}
+
+// formatCPUTime 和 ps 的 TIME 列一致，格式为 [DD-]HH:MM:SS
+func formatCPUTime(d time.Duration) string {
+	seconds := int64(d.Seconds())
+	days, seconds := seconds/86400, seconds%86400
+	result := fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	if days > 0 {
+		result = fmt.Sprintf("%d-%s", days, result)
+	}
+	return result
+}
+
+// psTop 在宿主机上执行 ps，根据 PID 列只输出容器中的进程
+func psTop(pids []int, psArgs []string) error {
+	output, err := exec.Command("ps", psArgs...).Output()
+	if err != nil {
+		return fmt.Errorf("run ps %s error: %w", strings.Join(psArgs, " "), err)
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return fmt.Errorf("ps %s returned no output", strings.Join(psArgs, " "))
+	}
+	pidIndex := -1
+	for i, field := range strings.Fields(lines[0]) {
+		if field == "PID" {
+			pidIndex = i
+			break
+		}
+	}
+	if pidIndex == -1 {
+		return fmt.Errorf("couldn't find PID field in ps output")
+	}
+
+	inContainer := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		inContainer[pid] = true
+	}
+	fmt.Fprintln(os.Stdout, lines[0])
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) <= pidIndex {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[pidIndex]); err == nil && inContainer[pid] {
+			fmt.Fprintln(os.Stdout, line)
+		}
+	}
+	return nil
+}
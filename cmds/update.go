@@ -0,0 +1,128 @@
+package cmds
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/cgroups"
+	"github.com/wangstu/mydocker/cgroups/subsystems"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/utils"
+)
+
+// UpdateOptions update 命令的参数，空字符串和 0 表示不修改，CpuCfsQuota、PidsLimit 为 -1 表示去掉对应的限制
+type UpdateOptions struct {
+	Memory      string
+	CpuCfsQuota int
+	CpuSet      string
+	PidsLimit   int
+}
+
+// UpdateContainer 修改容器的资源限制并保存到容器信息中，之后重启容器时使用新的配置
+/*
+1）运行中和暂停的容器直接修改 cgroup 文件，新的限制不能低于当前的使用量
+2）其他状态的容器只保存配置，下次启动时生效
+*/
+func UpdateContainer(containerId string, opts UpdateOptions) error {
+	if opts.Memory == "" && opts.CpuCfsQuota == 0 && opts.CpuSet == "" && opts.PidsLimit == 0 {
+		return fmt.Errorf("you must provide one or more flags when using this command")
+	}
+	memoryLimit, err := validateUpdateOptions(opts)
+	if err != nil {
+		return err
+	}
+	// 先修正 monitor 已经退出的容器的状态
+	if _, err = getInfoByContainerId(containerId); err != nil {
+		return fmt.Errorf("get container info error: %w", err)
+	}
+
+	containerInfo, err := container.ModifyContainerInfo(containerId, func(info *container.Info) error {
+		res := &subsystems.ResourceConfig{}
+		if info.ResourceConfig != nil {
+			*res = *info.ResourceConfig
+		}
+		if opts.Memory != "" {
+			res.MemoryLimit = strconv.FormatInt(memoryLimit, 10)
+		}
+		if opts.CpuCfsQuota > 0 {
+			res.CpuCfsQuota = opts.CpuCfsQuota
+		} else if opts.CpuCfsQuota < 0 {
+			res.CpuCfsQuota = 0
+		}
+		if opts.CpuSet != "" {
+			res.CpuSet = opts.CpuSet
+		}
+		if opts.PidsLimit > 0 {
+			res.PidsLimit = opts.PidsLimit
+		} else if opts.PidsLimit < 0 {
+			res.PidsLimit = 0
+		}
+
+		if info.Status == container.RUNNING || info.Status == container.PAUSED {
+			if err := updateContainerCgroup(containerId, res, memoryLimit); err != nil {
+				return err
+			}
+		}
+		info.ResourceConfig = res
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logContainerEvent(containerInfo, "update")
+	logrus.Infof("container %s updated", containerId)
+	return nil
+}
+
+// validateUpdateOptions 检查参数的格式，返回内存限制的字节数
+func validateUpdateOptions(opts UpdateOptions) (int64, error) {
+	var memoryLimit int64
+	if opts.Memory != "" {
+		var err error
+		if memoryLimit, err = utils.ParseSize(opts.Memory); err != nil {
+			return 0, err
+		}
+		if memoryLimit == 0 {
+			return 0, fmt.Errorf("memory limit must be positive")
+		}
+	}
+	if opts.CpuCfsQuota < -1 {
+		return 0, fmt.Errorf("cpu quota must be positive, or -1 for unlimited")
+	}
+	if opts.CpuSet != "" {
+		if err := (&subsystems.CpusetSubSystem{}).Validate(opts.CpuSet); err != nil {
+			return 0, err
+		}
+	}
+	if opts.PidsLimit < -1 {
+		return 0, fmt.Errorf("pids limit must be positive, or -1 for unlimited")
+	}
+	return memoryLimit, nil
+}
+
+// updateContainerCgroup 和当前的使用量比较之后修改 cgroup 中的资源限制
+func updateContainerCgroup(containerId string, res *subsystems.ResourceConfig, memoryLimit int64) error {
+	cgroupManager := cgroups.NewCgroupManager(getCgroupPath(containerId), res)
+	stats, err := cgroupManager.GetStats()
+	if err != nil {
+		return err
+	}
+	if memoryLimit > 0 && uint64(memoryLimit) < stats.Memory.Usage {
+		return fmt.Errorf("memory limit %s is lower than current usage %s",
+			utils.HumanSize(memoryLimit), utils.HumanSize(int64(stats.Memory.Usage)))
+	}
+	if res.PidsLimit > 0 && uint64(res.PidsLimit) < stats.Pids.Current {
+		return fmt.Errorf("pids limit %d is lower than current number of processes %d", res.PidsLimit, stats.Pids.Current)
+	}
+
+	pids, err := cgroupManager.GetPids()
+	if err != nil {
+		return fmt.Errorf("get processes of container %s error: %w", containerId, err)
+	}
+	if err = cgroupManager.Update(pids); err != nil {
+		return fmt.Errorf("update cgroup of container %s error: %w", containerId, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+// WaitContainer 阻塞直到容器进入退出状态，返回容器的退出码
+/*
+容器的退出状态由 monitor 进程写入 config.json，这里通过轮询持久化的容器信息来等待，
+所以可以等待任意进程启动的容器
+*/
+func WaitContainer(containerId string) (int, error) {
+	for {
+		containerInfo, err := getInfoByContainerId(containerId)
+		if errors.Is(err, fs.ErrNotExist) {
+			// 设置了 --rm 的容器退出后会被立即删除，来不及读取退出码
+			return -1, fmt.Errorf("container %s has been removed", containerId)
+		}
+		if err != nil {
+			return -1, fmt.Errorf("get container info error: %w", err)
+		}
+		if isContainerExited(containerInfo) {
+			return containerInfo.ExitCode, nil
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+// isContainerExited 容器已停止且 monitor 已记录退出状态，等待重启的容器不算退出
+func isContainerExited(containerInfo *container.Info) bool {
+	if containerInfo.Pid != "" {
+		return false
+	}
+	return containerInfo.Status == container.Exit || containerInfo.Status == container.STOP
+}
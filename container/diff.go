@@ -0,0 +1,126 @@
+package container
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/wangstu/mydocker/utils"
+)
+
+const (
+	ChangeModify = "C"
+	ChangeAdd    = "A"
+	ChangeDelete = "D"
+)
+
+// opaqueXattrs 标记 opaque 目录的 xattr，使用 userxattr 挂载时为 user.overlay.opaque
+var opaqueXattrs = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+// Change 容器文件系统相对于镜像的一个变化，Path 为容器中的绝对路径
+type Change struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+// GetChanges 比较容器的 upper 层和 lower 层（镜像），返回按路径排序的变化
+func GetChanges(containerId string) ([]Change, error) {
+	return overlayChanges(utils.GetLowerPath(containerId), utils.GetUpperPath(containerId))
+}
+
+// overlayChanges 遍历 upper 层，根据 lower 层中是否存在同名文件判断是新增还是修改
+/*
+overlayfs 中删除和替换的表示方式：
+1）删除 lower 层中的文件或目录时，upper 层中会创建一个同名的 0:0 字符设备（whiteout）
+2）删除目录后重新创建同名目录时，upper 层中的目录带有 overlay.opaque=y 的 xattr，
+   lower 层中该目录下的内容全部被隐藏（包括其中在 upper 层重新创建的子目录），没有在 upper 层重新创建的都视为被删除
+3）lower 层中的目录因为其中的文件发生变化被复制到 upper 层，和 docker 一样视为修改
+*/
+func overlayChanges(lower, upper string) ([]Change, error) {
+	var changes []Change
+	// hidden 记录 lower 层中内容被隐藏的目录，WalkDir 先访问父目录，子目录继承父目录的状态
+	hidden := map[string]bool{}
+	err := filepath.WalkDir(upper, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		containerPath := "/" + rel
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if isWhiteout(info) {
+			changes = append(changes, Change{Kind: ChangeDelete, Path: containerPath})
+			return nil
+		}
+
+		kind := ChangeAdd
+		lowerInfo, err := os.Lstat(filepath.Join(lower, rel))
+		if err == nil {
+			kind = ChangeModify
+		}
+		changes = append(changes, Change{Kind: kind, Path: containerPath})
+
+		if !d.IsDir() {
+			return nil
+		}
+		hidden[rel] = hidden[filepath.Dir(rel)] || isOpaque(p)
+		// lower 层中同名的可能是文件，这时没有被隐藏的内容
+		if hidden[rel] && lowerInfo != nil && lowerInfo.IsDir() {
+			deleted, err := opaqueDeletions(filepath.Join(lower, rel), p, containerPath)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, deleted...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// opaqueDeletions lower 层中被 opaque 目录隐藏、又没有在 upper 层重新创建的文件，只列出最上层被删除的路径
+func opaqueDeletions(lowerDir, upperDir, containerPath string) ([]Change, error) {
+	entries, err := os.ReadDir(lowerDir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, entry := range entries {
+		if _, err = os.Lstat(filepath.Join(upperDir, entry.Name())); err == nil {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeDelete, Path: filepath.Join(containerPath, entry.Name())})
+	}
+	return changes, nil
+}
+
+func isWhiteout(info fs.FileInfo) bool {
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+func isOpaque(dir string) bool {
+	value := make([]byte, 1)
+	for _, attr := range opaqueXattrs {
+		if n, err := syscall.Getxattr(dir, attr, value); err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
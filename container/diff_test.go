@@ -0,0 +1,72 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files ...string) {
+	for _, file := range files {
+		p := filepath.Join(root, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Nil(t, os.WriteFile(p, []byte(file), 0644))
+	}
+}
+
+func TestOverlayChanges(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	writeFiles(t, lower, "etc/passwd", "etc/hosts", "bin/sh")
+	writeFiles(t, upper, "etc/passwd", "etc/app/app.conf", "tmp.log")
+
+	changes, err := overlayChanges(lower, upper)
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Kind: ChangeModify, Path: "/etc"},
+		{Kind: ChangeAdd, Path: "/etc/app"},
+		{Kind: ChangeAdd, Path: "/etc/app/app.conf"},
+		{Kind: ChangeModify, Path: "/etc/passwd"},
+		{Kind: ChangeAdd, Path: "/tmp.log"},
+	}, changes)
+}
+
+func TestOverlayChangesWhiteout(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	writeFiles(t, lower, "bin/sh", "bin/cat")
+	assert.Nil(t, os.MkdirAll(filepath.Join(upper, "bin"), 0755))
+	if err := syscall.Mknod(filepath.Join(upper, "bin/sh"), syscall.S_IFCHR, 0); err != nil {
+		t.Skipf("create whiteout error: %v", err)
+	}
+
+	changes, err := overlayChanges(lower, upper)
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Kind: ChangeModify, Path: "/bin"},
+		{Kind: ChangeDelete, Path: "/bin/sh"},
+	}, changes)
+}
+
+func TestOverlayChangesOpaque(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	writeFiles(t, lower, "opt/app/bin", "opt/app/lib", "opt/data", "opt/file")
+	// /opt 被删除后重新创建，其中只重新创建了 /opt/app/bin 和 /opt/file 为目录
+	writeFiles(t, upper, "opt/app/bin", "opt/file/new")
+	if err := syscall.Setxattr(filepath.Join(upper, "opt"), opaqueXattrs[0], []byte("y"), 0); err != nil {
+		t.Skipf("set opaque xattr error: %v", err)
+	}
+
+	changes, err := overlayChanges(lower, upper)
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Kind: ChangeModify, Path: "/opt"},
+		{Kind: ChangeModify, Path: "/opt/app"},
+		{Kind: ChangeModify, Path: "/opt/app/bin"},
+		{Kind: ChangeDelete, Path: "/opt/app/lib"},
+		{Kind: ChangeDelete, Path: "/opt/data"},
+		{Kind: ChangeModify, Path: "/opt/file"},
+		{Kind: ChangeAdd, Path: "/opt/file/new"},
+	}, changes)
+}
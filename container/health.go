@@ -0,0 +1,64 @@
+package container
+
+import "time"
+
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+
+	// 只保留最近几次健康检查的结果
+	MaxHealthLogEntries = 5
+	// 每次健康检查最多保留的输出长度
+	MaxHealthOutputLength = 4096
+
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthTimeout  = 30 * time.Second
+	DefaultHealthRetries  = 3
+)
+
+// HealthConfig 健康检查的配置，Cmd 会通过 nsenter 在容器中用 sh -c 执行
+type HealthConfig struct {
+	Cmd      string        `json:"cmd"`
+	Interval time.Duration `json:"interval"`
+	Timeout  time.Duration `json:"timeout"`
+	Retries  int           `json:"retries"`
+	// 变为 unhealthy 时杀死并重新启动容器，不受重启策略的限制
+	RestartOnUnhealthy bool `json:"restartOnUnhealthy"`
+}
+
+// HealthResult 一次健康检查的结果
+type HealthResult struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+}
+
+// Health 容器当前的健康状态
+type Health struct {
+	Status        string         `json:"status"`
+	FailingStreak int            `json:"failingStreak"`
+	Log           []HealthResult `json:"log"`
+}
+
+// AddResult 记录一次健康检查的结果，成功一次即为 healthy，连续失败 retries 次变为 unhealthy
+func (h *Health) AddResult(result HealthResult, retries int) {
+	if len(result.Output) > MaxHealthOutputLength {
+		result.Output = result.Output[:MaxHealthOutputLength]
+	}
+	h.Log = append(h.Log, result)
+	if len(h.Log) > MaxHealthLogEntries {
+		h.Log = h.Log[len(h.Log)-MaxHealthLogEntries:]
+	}
+
+	if result.ExitCode == 0 {
+		h.Status = HealthHealthy
+		h.FailingStreak = 0
+		return
+	}
+	h.FailingStreak++
+	if h.FailingStreak >= retries {
+		h.Status = HealthUnhealthy
+	}
+}
@@ -0,0 +1,38 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthAddResult(t *testing.T) {
+	h := &Health{Status: HealthStarting}
+
+	h.AddResult(HealthResult{ExitCode: 1}, 2)
+	assert.Equal(t, HealthStarting, h.Status)
+	assert.Equal(t, 1, h.FailingStreak)
+
+	h.AddResult(HealthResult{ExitCode: 0}, 2)
+	assert.Equal(t, HealthHealthy, h.Status)
+	assert.Equal(t, 0, h.FailingStreak)
+
+	h.AddResult(HealthResult{ExitCode: 1}, 2)
+	assert.Equal(t, HealthHealthy, h.Status)
+	h.AddResult(HealthResult{ExitCode: -1}, 2)
+	assert.Equal(t, HealthUnhealthy, h.Status)
+	assert.Equal(t, 2, h.FailingStreak)
+}
+
+func TestHealthLogBounded(t *testing.T) {
+	h := &Health{Status: HealthStarting}
+	for i := 0; i < MaxHealthLogEntries+3; i++ {
+		h.AddResult(HealthResult{ExitCode: i}, 100)
+	}
+	assert.Equal(t, MaxHealthLogEntries, len(h.Log))
+	assert.Equal(t, 3, h.Log[0].ExitCode)
+
+	long := make([]byte, MaxHealthOutputLength+10)
+	h.AddResult(HealthResult{Output: string(long)}, 100)
+	assert.Equal(t, MaxHealthOutputLength, len(h.Log[len(h.Log)-1].Output))
+}
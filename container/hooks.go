@@ -0,0 +1,231 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HookCreateRuntime = "createRuntime"
+	HookPrestart      = "prestart"
+	HookPoststart     = "poststart"
+	HookPoststop      = "poststop"
+
+	// HooksDir 全局 hook 配置目录，其中的每个 json 文件描述一个 hook
+	HooksDir = "/etc/mydocker/hooks.d/"
+
+	OCIVersion         = "1.0.2"
+	DefaultHookTimeout = 30 * time.Second
+)
+
+var hookStages = []string{HookCreateRuntime, HookPrestart, HookPoststart, HookPoststop}
+
+// Hook 和 OCI runtime spec 中的 hook 一致，Args 包括 argv[0]，Timeout 单位为秒
+type Hook struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Timeout int      `json:"timeout,omitempty"`
+}
+
+// Hooks 各个阶段需要执行的 hook
+type Hooks struct {
+	CreateRuntime []Hook `json:"createRuntime,omitempty"`
+	Prestart      []Hook `json:"prestart,omitempty"`
+	Poststart     []Hook `json:"poststart,omitempty"`
+	Poststop      []Hook `json:"poststop,omitempty"`
+}
+
+// State 通过 stdin 传递给 hook 的容器状态
+type State struct {
+	OCIVersion  string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// hookConfig 全局 hook 目录中的配置文件格式，stages 为需要执行该 hook 的阶段
+type hookConfig struct {
+	Version string   `json:"version"`
+	Hook    Hook     `json:"hook"`
+	Stages  []string `json:"stages"`
+}
+
+// Get 返回某个阶段的 hook，h 为 nil 时返回空
+func (h *Hooks) Get(stage string) []Hook {
+	if h == nil {
+		return nil
+	}
+	switch stage {
+	case HookCreateRuntime:
+		return h.CreateRuntime
+	case HookPrestart:
+		return h.Prestart
+	case HookPoststart:
+		return h.Poststart
+	case HookPoststop:
+		return h.Poststop
+	default:
+		return nil
+	}
+}
+
+// Add 添加某个阶段的 hook
+func (h *Hooks) Add(stage string, hook Hook) error {
+	switch stage {
+	case HookCreateRuntime:
+		h.CreateRuntime = append(h.CreateRuntime, hook)
+	case HookPrestart:
+		h.Prestart = append(h.Prestart, hook)
+	case HookPoststart:
+		h.Poststart = append(h.Poststart, hook)
+	case HookPoststop:
+		h.Poststop = append(h.Poststop, hook)
+	default:
+		return fmt.Errorf("invalid hook stage %s, must be one of %s", stage, strings.Join(hookStages, ", "))
+	}
+	return nil
+}
+
+// ParseHook 解析 --hook 参数，格式为 stage=path[,arg...]
+func ParseHook(value string) (string, Hook, error) {
+	stage, command, found := strings.Cut(value, "=")
+	if !found || command == "" {
+		return "", Hook{}, fmt.Errorf("invalid hook %s, must be stage=path[,arg...]", value)
+	}
+	args := strings.Split(command, ",")
+	hook := Hook{Path: args[0], Args: args}
+	if err := hook.validate(); err != nil {
+		return "", Hook{}, err
+	}
+	if err := (&Hooks{}).Add(stage, hook); err != nil {
+		return "", Hook{}, err
+	}
+	return stage, hook, nil
+}
+
+func (hook *Hook) validate() error {
+	if !path.IsAbs(hook.Path) {
+		return fmt.Errorf("hook path %s must be absolute", hook.Path)
+	}
+	if hook.Timeout < 0 {
+		return fmt.Errorf("hook timeout of %s must not be negative", hook.Path)
+	}
+	return nil
+}
+
+// GetTimeout hook 的超时时间，没有配置时使用 DefaultHookTimeout
+func (hook *Hook) GetTimeout() time.Duration {
+	if hook.Timeout > 0 {
+		return time.Duration(hook.Timeout) * time.Second
+	}
+	return DefaultHookTimeout
+}
+
+// LoadHooksDir 按文件名顺序加载目录中的 hook 配置，无效的配置文件会被跳过
+func LoadHooksDir(dir string) *Hooks {
+	hooks := &Hooks{}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		logrus.Errorf("list hooks in %s error: %v", dir, err)
+		return hooks
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logrus.Errorf("read hook config %s error: %v", file, err)
+			continue
+		}
+		config := &hookConfig{}
+		if err = json.Unmarshal(content, config); err != nil {
+			logrus.Errorf("unmarshal hook config %s error: %v", file, err)
+			continue
+		}
+		if err = config.Hook.validate(); err != nil {
+			logrus.Errorf("invalid hook config %s: %v", file, err)
+			continue
+		}
+		for _, stage := range config.Stages {
+			if err = hooks.Add(stage, config.Hook); err != nil {
+				logrus.Errorf("invalid hook config %s: %v", file, err)
+			}
+		}
+	}
+	return hooks
+}
+
+// RunHooks 依次执行 hook，遇到第一个失败的 hook 时返回错误，如何处理错误由调用方根据阶段决定
+func RunHooks(stage string, hooks []Hook, state *State) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	stateJson, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal container state error: %w", err)
+	}
+	for _, hook := range hooks {
+		if err = runHook(hook, stateJson); err != nil {
+			return fmt.Errorf("%s hook %s error: %w", stage, hook.Path, err)
+		}
+		logrus.Infof("run %s hook %s successfully", stage, hook.Path)
+	}
+	return nil
+}
+
+func runHook(hook Hook, stateJson []byte) error {
+	args := hook.Args
+	if len(args) == 0 {
+		args = []string{hook.Path}
+	}
+	// 和 OCI 一致，hook 只使用配置的环境变量，Env 为 nil 时 exec 会继承 mydocker 自己的环境变量
+	env := hook.Env
+	if env == nil {
+		env = []string{}
+	}
+	var output bytes.Buffer
+	cmd := &exec.Cmd{
+		Path:   hook.Path,
+		Args:   args,
+		Env:    env,
+		Stdin:  bytes.NewReader(stateJson),
+		Stdout: &output,
+		Stderr: &output,
+		// hook 可能启动子进程，超时后杀死整个进程组
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true},
+		WaitDelay:   time.Second,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	timeout := hook.GetTimeout()
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-waitCh:
+		if err != nil {
+			return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(output.String()))
+		}
+		return nil
+	case <-timer.C:
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-waitCh
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHook(t *testing.T) {
+	stage, hook, err := ParseHook("prestart=/usr/local/bin/setup,--net,br0")
+	assert.Nil(t, err)
+	assert.Equal(t, HookPrestart, stage)
+	assert.Equal(t, "/usr/local/bin/setup", hook.Path)
+	assert.Equal(t, []string{"/usr/local/bin/setup", "--net", "br0"}, hook.Args)
+
+	for _, value := range []string{"prestart", "prestart=", "prestart=setup", "unknown=/bin/true"} {
+		_, _, err = ParseHook(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "state.json")
+	hooks := []Hook{{Path: "/bin/sh", Args: []string{"sh", "-c", "cat > " + out}}}
+	state := &State{OCIVersion: OCIVersion, ID: "abc", Status: "created", Pid: 1}
+	assert.Nil(t, RunHooks(HookPrestart, hooks, state))
+
+	content, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	got := &State{}
+	assert.Nil(t, json.Unmarshal(content, got))
+	assert.Equal(t, state, got)
+
+	failed := []Hook{{Path: "/bin/sh", Args: []string{"sh", "-c", "echo oops; exit 3"}}}
+	err = RunHooks(HookPrestart, failed, state)
+	assert.ErrorContains(t, err, "oops")
+
+	// hook 不继承 mydocker 的环境变量
+	t.Setenv("MYDOCKER_HOOK_TEST", "leak")
+	envOut := filepath.Join(dir, "env")
+	for _, env := range [][]string{nil, {"FOO=bar"}} {
+		hooks = []Hook{{Path: "/bin/sh", Args: []string{"sh", "-c", "env > " + envOut}, Env: env}}
+		assert.Nil(t, RunHooks(HookPrestart, hooks, state))
+		content, err = os.ReadFile(envOut)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(content), "MYDOCKER_HOOK_TEST")
+		for _, e := range env {
+			assert.Contains(t, string(content), e)
+		}
+	}
+
+	slow := []Hook{{Path: "/bin/sh", Args: []string{"sh", "-c", "sleep 10"}, Timeout: 1}}
+	err = RunHooks(HookPoststop, slow, state)
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestLoadHooksDir(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"version": "1.0.0", "hook": {"path": "/bin/true"}, "stages": ["prestart", "poststop"]}`
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "10-net.json"), []byte(config), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "20-bad.json"), []byte(`{`), 0644))
+
+	hooks := LoadHooksDir(dir)
+	assert.Equal(t, 1, len(hooks.Get(HookPrestart)))
+	assert.Equal(t, 1, len(hooks.Get(HookPoststop)))
+	assert.Equal(t, 0, len(hooks.Get(HookPoststart)))
+	assert.Nil(t, (*Hooks)(nil).Get(HookPrestart))
+}
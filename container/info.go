@@ -1,81 +1,125 @@
 package container
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
 	"path"
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/cgroups/subsystems"
+	"github.com/wangstu/mydocker/store"
 )
 
 const (
+	CREATED       = "created"
 	RUNNING       = "running"
+	RESTARTING    = "restarting"
+	PAUSED        = "paused"
 	STOP          = "stopped"
 	Exit          = "exited"
+	TimeFormat    = "2006-01-02 15:04:05"
 	InfoLoc       = "/var/lib/mydocker/containers/"
 	InfoLocFormat = InfoLoc + "%s/"
 	ConfigName    = "config.json"
 	IDLength      = 10
+
+	storeKind = "container"
 )
 
 type Info struct {
-	Pid         string   `json:"pid"`
-	Id          string   `json:"id"`
-	Name        string   `json:"name"`
-	Command     string   `json:"command"`
-	CreateTime  string   `json:"createTime"`
-	Status      string   `json:"status"`
-	Volume      string   `json:"volume"`
-	NetworkName string   `json:"networkName"`
-	IP          string   `json:"ip"`
-	PortMapping []string `json:"portMapping"`
+	store.Meta
+	Pid            string                     `json:"pid"`
+	Id             string                     `json:"id"`
+	Name           string                     `json:"name"`
+	Command        string                     `json:"command"`
+	CreateTime     string                     `json:"createTime"`
+	Status         string                     `json:"status"`
+	Volume         string                     `json:"volume"`
+	NetworkName    string                     `json:"networkName"`
+	IP             string                     `json:"ip"`
+	PortMapping    []string                   `json:"portMapping"`
+	Ports          []PortBinding              `json:"ports,omitempty"`
+	Image          string                     `json:"image"`
+	Env            []string                   `json:"env"`
+	ResourceConfig *subsystems.ResourceConfig `json:"resourceConfig"`
+	StartedAt      string                     `json:"startedAt"`
+	ExitCode       int                        `json:"exitCode"`
+	FinishedAt     string                     `json:"finishedAt"`
+	OOMKilled      bool                       `json:"oomKilled"`
+	RestartPolicy  RestartPolicy              `json:"restartPolicy"`
+	RestartCount   int                        `json:"restartCount"`
+	StopSignal     string                     `json:"stopSignal"`
+	AutoRemove     bool                       `json:"autoRemove"`
+	HealthConfig   *HealthConfig              `json:"healthConfig,omitempty"`
+	Health         *Health                    `json:"health,omitempty"`
+	Hooks          *Hooks                     `json:"hooks,omitempty"`
+	Labels         map[string]string          `json:"labels,omitempty"`
+	// 记录 init 进程和 monitor 进程的启动时间，用来识别 pid 是否已经被其他进程复用
+	PidStartTime     uint64 `json:"pidStartTime"`
+	MonitorPid       int    `json:"monitorPid"`
+	MonitorStartTime uint64 `json:"monitorStartTime"`
 }
 
-func RecordContainerInfo(containerPID int, cmds []string,
-	containerName, containerId, volume, networkName, ip string, portMapping []string) (*Info, error) {
-	if containerName == "" {
-		containerName = containerId
+// RecordContainerInfo 第一次记录容器信息，补全容器名称和创建时间
+func RecordContainerInfo(containerInfo *Info) error {
+	if containerInfo.Name == "" {
+		containerInfo.Name = containerInfo.Id
 	}
-	command := strings.Join(cmds, " ")
-	containerInfo := &Info{
-		Name:        containerName,
-		Id:          containerId,
-		Pid:         strconv.Itoa(containerPID),
-		Command:     command,
-		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
-		Status:      RUNNING,
-		Volume:      volume,
-		NetworkName: networkName,
-		IP:          ip,
-		PortMapping: portMapping,
+	if containerInfo.CreateTime == "" {
+		containerInfo.CreateTime = time.Now().Format(TimeFormat)
 	}
-	jsonBytes, err := json.MarshalIndent(containerInfo, "", "  ")
-	if err != nil {
-		return containerInfo, fmt.Errorf("marshal container info error: %w", err)
+	return UpdateContainerInfo(containerInfo)
+}
+
+// UpdateContainerInfo 将容器信息原子地覆盖写入 config.json
+// NOTE: 先读取再修改的场景需要使用 ModifyContainerInfo，避免覆盖其他进程的修改
+func UpdateContainerInfo(containerInfo *Info) error {
+	if err := store.WriteJSON(getInfoFilePath(containerInfo.Id), containerInfo); err != nil {
+		return fmt.Errorf("write container info error: %w", err)
+	}
+	return nil
+}
+
+// ReadContainerInfo 读取 config.json 中的容器信息
+func ReadContainerInfo(containerId string) (*Info, error) {
+	containerInfo := &Info{}
+	if err := store.ReadJSON(storeKind, getInfoFilePath(containerId), containerInfo); err != nil {
+		return nil, fmt.Errorf("read container info error: %w", err)
 	}
-	jsonStr := string(jsonBytes)
+	return containerInfo, nil
+}
+
+// LockContainerInfo 获取容器信息的文件锁
+func LockContainerInfo(containerId string) (*store.Lock, error) {
+	return store.LockPath(getInfoFilePath(containerId))
+}
 
-	infoFolder := fmt.Sprintf(InfoLocFormat, containerId)
-	if err := os.MkdirAll(infoFolder, constant.Perm0644); err != nil && !os.IsExist(err) {
-		return containerInfo, fmt.Errorf("mkdir %s error: %w", infoFolder, err)
+// ModifyContainerInfo 在容器锁内读取最新的容器信息，交给 fn 修改后写回，fn 返回错误时不写回
+func ModifyContainerInfo(containerId string, fn func(containerInfo *Info) error) (*Info, error) {
+	lock, err := LockContainerInfo(containerId)
+	if err != nil {
+		return nil, err
 	}
+	defer lock.Unlock()
 
-	infoFilePath := path.Join(infoFolder, ConfigName)
-	file, err := os.Create(infoFilePath)
+	containerInfo, err := ReadContainerInfo(containerId)
 	if err != nil {
-		return containerInfo, fmt.Errorf("create file %s error: %w", infoFilePath, err)
+		return nil, err
+	}
+	if err = fn(containerInfo); err != nil {
+		return containerInfo, err
 	}
-	if _, err = file.WriteString(jsonStr); err != nil {
-		return containerInfo, fmt.Errorf("write container info to file %s error: %w", infoFilePath, err)
+	if err = UpdateContainerInfo(containerInfo); err != nil {
+		return nil, err
 	}
 	return containerInfo, nil
 }
 
+func getInfoFilePath(containerId string) string {
+	return path.Join(fmt.Sprintf(InfoLocFormat, containerId), ConfigName)
+}
+
 func DeleteContainerInfo(containerId string) error {
 	infoFilePath := fmt.Sprintf(InfoLocFormat, containerId)
 	if err := os.RemoveAll(infoFilePath); err != nil {
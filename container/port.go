@@ -0,0 +1,98 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// PortBinding 宿主机端口到容器端口的映射，HostIP 为空表示监听所有地址
+// 连接网络时根据 -p 参数分配并记录在 Info.Ports 中，容器退出后清空
+type PortBinding struct {
+	HostIP        string `json:"hostIp,omitempty"`
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// ParsePortMapping 解析 -p 参数，格式为 [hostIP:][hostPort:]containerPort[/protocol]
+// 没有指定宿主机端口时 HostPort 为 0，连接网络时再分配
+func ParsePortMapping(spec string) (*PortBinding, error) {
+	rest, protocol, found := strings.Cut(spec, "/")
+	if !found {
+		protocol = ProtocolTCP
+	}
+	binding := &PortBinding{Protocol: strings.ToLower(protocol)}
+	if binding.Protocol != ProtocolTCP && binding.Protocol != ProtocolUDP {
+		return nil, fmt.Errorf("invalid port mapping %s: unsupported protocol %s", spec, protocol)
+	}
+
+	var hostPort, containerPort string
+	parts := strings.Split(rest, ":")
+	switch len(parts) {
+	case 1:
+		containerPort = parts[0]
+	case 2:
+		hostPort, containerPort = parts[0], parts[1]
+	case 3:
+		binding.HostIP, hostPort, containerPort = parts[0], parts[1], parts[2]
+		if net.ParseIP(binding.HostIP).To4() == nil {
+			return nil, fmt.Errorf("invalid port mapping %s: invalid host ip %s", spec, binding.HostIP)
+		}
+	default:
+		return nil, fmt.Errorf("invalid port mapping %s, must be [hostIP:][hostPort:]containerPort[/protocol]", spec)
+	}
+
+	var err error
+	if binding.ContainerPort, err = parsePort(containerPort); err != nil {
+		return nil, fmt.Errorf("invalid port mapping %s: %w", spec, err)
+	}
+	if hostPort != "" {
+		if binding.HostPort, err = parsePort(hostPort); err != nil {
+			return nil, fmt.Errorf("invalid port mapping %s: %w", spec, err)
+		}
+	}
+	return binding, nil
+}
+
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return p, nil
+}
+
+// ContainerPortProto 容器端口和协议，比如 80/tcp
+func (b PortBinding) ContainerPortProto() string {
+	return fmt.Sprintf("%d/%s", b.ContainerPort, b.Protocol)
+}
+
+// HostAddr 宿主机上的地址，比如 0.0.0.0:8080
+func (b PortBinding) HostAddr() string {
+	hostIP := b.HostIP
+	if hostIP == "" {
+		hostIP = "0.0.0.0"
+	}
+	return net.JoinHostPort(hostIP, strconv.Itoa(b.HostPort))
+}
+
+// String 和 docker ps 的格式一致，比如 0.0.0.0:8080->80/tcp
+func (b PortBinding) String() string {
+	return b.HostAddr() + "->" + b.ContainerPortProto()
+}
+
+// FormatPortBindings 用逗号连接所有的端口映射，用于 ps 的 PORTS 列
+func FormatPortBindings(bindings []PortBinding) string {
+	result := make([]string, 0, len(bindings))
+	for _, binding := range bindings {
+		result = append(result, binding.String())
+	}
+	return strings.Join(result, ", ")
+}
@@ -0,0 +1,38 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		spec string
+		want PortBinding
+	}{
+		{"8080:80", PortBinding{HostPort: 8080, ContainerPort: 80, Protocol: ProtocolTCP}},
+		{"53:53/udp", PortBinding{HostPort: 53, ContainerPort: 53, Protocol: ProtocolUDP}},
+		{"127.0.0.1:3306:3306", PortBinding{HostIP: "127.0.0.1", HostPort: 3306, ContainerPort: 3306, Protocol: ProtocolTCP}},
+		{"127.0.0.1::80/TCP", PortBinding{HostIP: "127.0.0.1", ContainerPort: 80, Protocol: ProtocolTCP}},
+		{"80", PortBinding{ContainerPort: 80, Protocol: ProtocolTCP}},
+	}
+	for _, test := range tests {
+		binding, err := ParsePortMapping(test.spec)
+		assert.Nil(t, err, test.spec)
+		assert.Equal(t, test.want, *binding, test.spec)
+	}
+
+	for _, spec := range []string{"", "8080:", "a:80", "70000:80", "80/sctp", "localhost:80:80", "1:2:3:4"} {
+		_, err := ParsePortMapping(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestFormatPortBindings(t *testing.T) {
+	bindings := []PortBinding{
+		{HostPort: 8080, ContainerPort: 80, Protocol: ProtocolTCP},
+		{HostIP: "127.0.0.1", HostPort: 53, ContainerPort: 53, Protocol: ProtocolUDP},
+	}
+	assert.Equal(t, "0.0.0.0:8080->80/tcp, 127.0.0.1:53->53/udp", FormatPortBindings(bindings))
+}
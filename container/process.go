@@ -52,7 +52,8 @@ func NewParentProcess(tty bool, volume, containerId, imageName string, envSlice
 			return nil, nil
 		}
 		logFilePath := path.Join(folder, GetLogFileName(containerId))
-		logFile, err := os.Create(logFilePath)
+		// 以追加方式打开日志文件，容器重新启动后保留之前的日志
+		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, constant.Perm0644)
 		if err != nil {
 			logrus.Errorf("create log file %s error: %v", logFilePath, err)
 			return nil, nil
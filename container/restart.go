@@ -0,0 +1,78 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RestartPolicyNo            = "no"
+	RestartPolicyAlways        = "always"
+	RestartPolicyOnFailure     = "on-failure"
+	RestartPolicyUnlessStopped = "unless-stopped"
+)
+
+// RestartPolicy 容器退出后的重启策略，MaximumRetryCount 只对 on-failure 生效，0 表示不限制次数
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"`
+}
+
+// ParseRestartPolicy 解析 --restart 参数，格式为 no|always|on-failure[:N]|unless-stopped
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	if policy == "" {
+		return RestartPolicy{Name: RestartPolicyNo}, nil
+	}
+
+	name, count, hasCount := strings.Cut(policy, ":")
+	switch name {
+	case RestartPolicyNo, RestartPolicyAlways, RestartPolicyUnlessStopped:
+		if hasCount {
+			return RestartPolicy{}, fmt.Errorf("maximum retry count can only be used with %s", RestartPolicyOnFailure)
+		}
+		return RestartPolicy{Name: name}, nil
+	case RestartPolicyOnFailure:
+		rp := RestartPolicy{Name: name}
+		if hasCount {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return RestartPolicy{}, fmt.Errorf("invalid maximum retry count: %s", count)
+			}
+			rp.MaximumRetryCount = n
+		}
+		return rp, nil
+	default:
+		return RestartPolicy{}, fmt.Errorf("invalid restart policy: %s", policy)
+	}
+}
+
+// IsNone 是否未设置重启策略
+func (rp RestartPolicy) IsNone() bool {
+	return rp.Name == "" || rp.Name == RestartPolicyNo
+}
+
+// ShouldRestart 根据退出码和已经重启的次数判断容器是否需要重启，手动停止的容器由调用方排除
+func (rp RestartPolicy) ShouldRestart(exitCode, restartCount int) bool {
+	switch rp.Name {
+	case RestartPolicyAlways, RestartPolicyUnlessStopped:
+		return true
+	case RestartPolicyOnFailure:
+		if exitCode == 0 {
+			return false
+		}
+		return rp.MaximumRetryCount == 0 || restartCount < rp.MaximumRetryCount
+	default:
+		return false
+	}
+}
+
+func (rp RestartPolicy) String() string {
+	if rp.Name == RestartPolicyOnFailure && rp.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", rp.Name, rp.MaximumRetryCount)
+	}
+	if rp.Name == "" {
+		return RestartPolicyNo
+	}
+	return rp.Name
+}
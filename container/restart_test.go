@@ -0,0 +1,42 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	cases := []struct {
+		policy string
+		want   RestartPolicy
+		hasErr bool
+	}{
+		{policy: "", want: RestartPolicy{Name: RestartPolicyNo}},
+		{policy: "always", want: RestartPolicy{Name: RestartPolicyAlways}},
+		{policy: "unless-stopped", want: RestartPolicy{Name: RestartPolicyUnlessStopped}},
+		{policy: "on-failure", want: RestartPolicy{Name: RestartPolicyOnFailure}},
+		{policy: "on-failure:3", want: RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 3}},
+		{policy: "on-failure:x", hasErr: true},
+		{policy: "always:3", hasErr: true},
+		{policy: "sometimes", hasErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseRestartPolicy(c.policy)
+		if c.hasErr {
+			assert.NotNil(t, err, c.policy)
+			continue
+		}
+		assert.Nil(t, err, c.policy)
+		assert.Equal(t, c.want, got, c.policy)
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	assert.False(t, RestartPolicy{Name: RestartPolicyNo}.ShouldRestart(1, 0))
+	assert.True(t, RestartPolicy{Name: RestartPolicyAlways}.ShouldRestart(0, 10))
+	assert.False(t, RestartPolicy{Name: RestartPolicyOnFailure}.ShouldRestart(0, 0))
+	assert.True(t, RestartPolicy{Name: RestartPolicyOnFailure}.ShouldRestart(1, 100))
+	assert.True(t, RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 2}.ShouldRestart(1, 1))
+	assert.False(t, RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 2}.ShouldRestart(1, 2))
+}
@@ -1,6 +1,7 @@
 package container
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 
@@ -32,6 +33,32 @@ func NewWorkSpace(containerId, imageName, volume string) {
 	}
 }
 
+// MountWorkSpace 重新挂载已有的 overlay 工作目录和 volume，已经挂载时不做处理
+// 容器停止后 merged 目录仍然保持挂载，宿主机重启之后才需要重新挂载
+func MountWorkSpace(containerId, volume string) error {
+	exist, err := utils.IsPathExist(utils.GetUpperPath(containerId))
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("workspace of container %s does not exist", containerId)
+	}
+
+	mntPath := utils.GetMergedPath(containerId)
+	mountOverlayFS(containerId)
+	if mounted, err := utils.IsMountPoint(mntPath); err != nil || !mounted {
+		return fmt.Errorf("mount overlay fs of container %s failed", containerId)
+	}
+	if volume != "" {
+		hostPath, containerPath, err := extractVolume(volume)
+		if err != nil {
+			return fmt.Errorf("extract volume error: %w", err)
+		}
+		mountVolume(mntPath, hostPath, containerPath)
+	}
+	return nil
+}
+
 func createLower(containerId, imageName string) {
 	lower := utils.GetLowerPath(containerId)
 	tarLower := utils.GetImagePath(imageName)
@@ -67,6 +94,11 @@ func createDirs(containerId string) {
 func mountOverlayFS(containerId string) {
 	option := utils.GetMountOption(containerId)
 	mergedPath := utils.GetMergedPath(containerId)
+	// 容器重新启动时 overlayfs 可能仍处于挂载状态，避免重复挂载
+	if mounted, err := utils.IsMountPoint(mergedPath); err == nil && mounted {
+		logrus.Infof("%s is already mounted", mergedPath)
+		return
+	}
 
 	// mount -t overlay/ fuse.fuse-overlayfs overlay -o lowerdir=/root/busybox,upperdir=/root/upper,workdir=/root/work /root/merged
 	driverType := "overlay"
@@ -104,8 +136,38 @@ func DeleteWorkSpace(containerId, volume string) {
 	deleteDirs(containerId)
 }
 
+// UmountAll 卸载容器目录中残留的所有挂载点，包括 volume 和 overlay
+// 用于没有容器记录、无法得知 volume 信息的场景，卸载之后仍有挂载点时返回错误，调用方不能删除目录
+func UmountAll(containerId string) error {
+	rootPath := utils.GetRootPath(containerId)
+	mountPoints, err := utils.GetMountPointsUnder(rootPath)
+	if err != nil {
+		return fmt.Errorf("get mount points under %s error: %w", rootPath, err)
+	}
+	for _, mountPoint := range mountPoints {
+		cmd := exec.Command("umount", mountPoint)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		logrus.Infof("umount leftover mount point: %s", cmd.String())
+		if err = cmd.Run(); err != nil {
+			logrus.Errorf("umount %s error: %v", mountPoint, err)
+		}
+	}
+
+	if mountPoints, err = utils.GetMountPointsUnder(rootPath); err != nil {
+		return fmt.Errorf("get mount points under %s error: %w", rootPath, err)
+	}
+	if len(mountPoints) > 0 {
+		return fmt.Errorf("%s is still mounted", mountPoints[0])
+	}
+	return nil
+}
+
 func umountOverlayFS(containerId string) {
 	mergedPath := utils.GetMergedPath(containerId)
+	if mounted, err := utils.IsMountPoint(mergedPath); err == nil && !mounted {
+		return
+	}
 	cmd := exec.Command("umount", mergedPath)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
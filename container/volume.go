@@ -9,6 +9,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/utils"
 )
 
 func mountVolume(mntPath, hostPath, containerPath string) {
@@ -24,6 +25,11 @@ func mountVolume(mntPath, hostPath, containerPath string) {
 		return
 	}
 
+	if mounted, err := utils.IsMountPoint(containerPathInHost); err == nil && mounted {
+		logrus.Infof("%s is already mounted", containerPathInHost)
+		return
+	}
+
 	// bind mount: mount -o bind /hostPath /containerPathInHost
 	cmd := exec.Command("mount", "-o", "bind", hostPath, containerPathInHost)
 	cmd.Stdout = os.Stdout
@@ -37,6 +43,9 @@ func mountVolume(mntPath, hostPath, containerPath string) {
 
 func umountVolume(mntPath, containerPath string) {
 	containerPathInHost := path.Join(mntPath, containerPath)
+	if mounted, err := utils.IsMountPoint(containerPathInHost); err == nil && !mounted {
+		return
+	}
 	cmd := exec.Command("umount", containerPathInHost)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -47,6 +56,12 @@ func umountVolume(mntPath, containerPath string) {
 	logrus.Infof("umount %s successfully", containerPathInHost)
 }
 
+// GetVolumeContainerPath 返回 volume 在容器中的挂载路径
+func GetVolumeContainerPath(volume string) (string, error) {
+	_, containerPath, err := extractVolume(volume)
+	return containerPath, err
+}
+
 func extractVolume(volume string) (hostPath, containerPath string, err error) {
 	parts := strings.Split(volume, ":")
 	if len(parts) != 2 {
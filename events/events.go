@@ -0,0 +1,245 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/store"
+)
+
+const (
+	TypeContainer = "container"
+	TypeNetwork   = "network"
+	TypeImage     = "image"
+
+	// LabelPrefix 对象的 label 记录在事件属性中时加上的前缀，避免和 name、exitCode 等内置属性冲突
+	LabelPrefix = "label."
+
+	// 跟随模式下读到日志末尾后的等待间隔
+	pollInterval = 200 * time.Millisecond
+)
+
+var (
+	// JournalPath 事件日志，每行一个 JSON 格式的事件，只追加不修改
+	JournalPath = "/var/lib/mydocker/events.log"
+	// MaxJournalSize 事件日志超过这个大小后轮转为 events.log.1，只保留一份旧日志
+	MaxJournalSize int64 = 16 << 20
+)
+
+// Event 一次生命周期的变化
+type Event struct {
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Time       time.Time         `json:"time"`
+}
+
+// Log 记录一个事件，记录失败不影响调用方的流程，只打印日志
+func Log(eventType, action, id string, attributes map[string]string) {
+	event := &Event{
+		Type:       eventType,
+		Action:     action,
+		ID:         id,
+		Attributes: attributes,
+		Time:       time.Now(),
+	}
+	if err := appendEvent(event); err != nil {
+		logrus.Errorf("record %s %s event error: %v", eventType, action, err)
+	}
+}
+
+// AddLabels 把 labels 加上 LabelPrefix 前缀后写入事件属性
+func AddLabels(attributes, labels map[string]string) {
+	for k, v := range labels {
+		attributes[LabelPrefix+k] = v
+	}
+}
+
+// Labels 返回事件属性中记录的 label，去掉 LabelPrefix 前缀
+func (e *Event) Labels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range e.Attributes {
+		if name, ok := strings.CutPrefix(k, LabelPrefix); ok {
+			labels[name] = v
+		}
+	}
+	return labels
+}
+
+func rotatedJournalPath() string {
+	return JournalPath + ".1"
+}
+
+func appendEvent(event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err = os.MkdirAll(filepath.Dir(JournalPath), constant.Perm0755); err != nil {
+		return err
+	}
+	// O_APPEND 保证每次写入都在文件末尾，加锁避免多个进程的写入交错
+	lock, err := store.LockPath(JournalPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	// 写入之后超过大小限制时先轮转，覆盖之前轮转出去的旧日志
+	if info, err := os.Stat(JournalPath); err == nil && info.Size()+int64(len(line)) > MaxJournalSize {
+		if err = os.Rename(JournalPath, rotatedJournalPath()); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(JournalPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, constant.Perm0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(line)
+	return err
+}
+
+// Watch 从头读取事件日志（包括轮转出去的旧日志），依次交给 fn 处理，fn 返回 false 时停止
+// follow 为 true 时读到末尾后继续等待新的事件，直到 until 之后（until 为零值时一直等待）
+func Watch(follow bool, until time.Time, fn func(event *Event) bool) error {
+	if stopped, err := watchRotated(fn); stopped || err != nil {
+		return err
+	}
+
+	file, err := os.Open(JournalPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("open %s error: %w", JournalPath, err)
+		}
+		if !follow {
+			return nil
+		}
+		// 还没有任何事件，等待日志文件被创建
+		if file, err = waitJournal(until); file == nil {
+			return err
+		}
+	}
+	defer func() {
+		file.Close()
+	}()
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	rotated := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("read %s error: %w", JournalPath, err)
+		}
+		if err == io.EOF {
+			// 可能读到了正在写入的半行，留到下次补全
+			partial = append(partial, line...)
+			if !follow || isExpired(until) {
+				return nil
+			}
+			if rotated {
+				// 轮转之后旧文件不会再被写入，读完之后切换到新的日志文件
+				file.Close()
+				if file, err = waitJournal(until); file == nil {
+					return err
+				}
+				reader.Reset(file)
+				partial, rotated = nil, false
+				continue
+			}
+			// 发现轮转时旧文件末尾可能还有没读到的事件，再读一次
+			if rotated = isRotated(file); !rotated {
+				time.Sleep(pollInterval)
+			}
+			continue
+		}
+		if len(partial) > 0 {
+			line = append(partial, line...)
+			partial = nil
+		}
+
+		if !handleLine(line, fn) {
+			return nil
+		}
+	}
+}
+
+// watchRotated 读取轮转出去的旧日志，返回 fn 是否要求停止
+func watchRotated(fn func(event *Event) bool) (bool, error) {
+	file, err := os.Open(rotatedJournalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("open %s error: %w", rotatedJournalPath(), err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read %s error: %w", rotatedJournalPath(), err)
+		}
+		if !handleLine(line, fn) {
+			return true, nil
+		}
+	}
+}
+
+// handleLine 解析一行事件交给 fn 处理，无效的行被跳过
+func handleLine(line []byte, fn func(event *Event) bool) bool {
+	event := &Event{}
+	if err := json.Unmarshal(bytes.TrimSpace(line), event); err != nil {
+		logrus.Warnf("skip invalid event %q: %v", line, err)
+		return true
+	}
+	return fn(event)
+}
+
+// isRotated 日志文件已经被轮转，JournalPath 不存在或者指向了新的文件
+func isRotated(file *os.File) bool {
+	current, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	latest, err := os.Stat(JournalPath)
+	if os.IsNotExist(err) {
+		return true
+	}
+	return err == nil && !os.SameFile(current, latest)
+}
+
+func waitJournal(until time.Time) (*os.File, error) {
+	for !isExpired(until) {
+		time.Sleep(pollInterval)
+		file, err := os.Open(JournalPath)
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("open %s error: %w", JournalPath, err)
+		}
+	}
+	return nil, nil
+}
+
+func isExpired(until time.Time) bool {
+	return !until.IsZero() && time.Now().After(until)
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogAndWatch(t *testing.T) {
+	JournalPath = filepath.Join(t.TempDir(), "events.log")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Log(TypeContainer, "start", "abc", map[string]string{"name": "test"})
+		}()
+	}
+	wg.Wait()
+
+	var got []*Event
+	assert.Nil(t, Watch(false, time.Time{}, func(event *Event) bool {
+		got = append(got, event)
+		return true
+	}))
+	assert.Equal(t, 20, len(got))
+	assert.Equal(t, "start", got[0].Action)
+	assert.Equal(t, "test", got[0].Attributes["name"])
+}
+
+func TestWatchFollow(t *testing.T) {
+	JournalPath = filepath.Join(t.TempDir(), "events.log")
+
+	go func() {
+		time.Sleep(3 * pollInterval)
+		Log(TypeContainer, "die", "abc", nil)
+	}()
+	var got *Event
+	assert.Nil(t, Watch(true, time.Now().Add(5*time.Second), func(event *Event) bool {
+		got = event
+		return false
+	}))
+	assert.Equal(t, "die", got.Action)
+
+	// until 之后不再等待新的事件
+	start := time.Now()
+	assert.Nil(t, Watch(true, start.Add(2*pollInterval), func(event *Event) bool {
+		return true
+	}))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRotate(t *testing.T) {
+	JournalPath = filepath.Join(t.TempDir(), "events.log")
+	MaxJournalSize = 1024
+	defer func() { MaxJournalSize = 16 << 20 }()
+
+	for i := 0; i < 40; i++ {
+		Log(TypeContainer, "start", strconv.Itoa(i), nil)
+	}
+	info, err := os.Stat(JournalPath)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, info.Size(), MaxJournalSize)
+	_, err = os.Stat(JournalPath + ".1")
+	assert.Nil(t, err)
+
+	// 旧日志中的事件先输出，最后一个事件在当前日志中
+	var ids []string
+	assert.Nil(t, Watch(false, time.Time{}, func(event *Event) bool {
+		ids = append(ids, event.ID)
+		return true
+	}))
+	assert.Less(t, len(ids), 40)
+	assert.Equal(t, "39", ids[len(ids)-1])
+	assert.True(t, sort.SliceIsSorted(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	}))
+
+	// 跟随模式下日志轮转之后继续读取新的日志文件
+	go func() {
+		time.Sleep(3 * pollInterval)
+		for i := 40; i < 80; i++ {
+			Log(TypeContainer, "start", strconv.Itoa(i), nil)
+		}
+	}()
+	var last string
+	assert.Nil(t, Watch(true, time.Now().Add(5*time.Second), func(event *Event) bool {
+		last = event.ID
+		return last != "79"
+	}))
+	assert.Equal(t, "79", last)
+}
+
+func TestEventLabels(t *testing.T) {
+	attrs := map[string]string{"name": "web"}
+	AddLabels(attrs, map[string]string{"name": "label-name", "exitCode": "1"})
+	event := &Event{Attributes: attrs}
+	assert.Equal(t, "web", event.Attributes["name"])
+	assert.Equal(t, map[string]string{"name": "label-name", "exitCode": "1"}, event.Labels())
+}
@@ -0,0 +1,82 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ApplyChanges 把 import --change 中的 Dockerfile 指令应用到镜像的元数据中，支持 CMD、ENV 和 LABEL
+/*
+1）CMD 支持 JSON 数组和 shell 两种形式，容器命令按空格传递给 init 进程，shell 形式直接按空格拆分，不会包装为 sh -c
+2）ENV 支持 ENV k=v k2=v2 和 ENV k v 两种形式，同名的环境变量以后面的为准
+3）LABEL 的格式为 LABEL k=v k2=v2
+*/
+func ApplyChanges(config *Config, changes []string) error {
+	for _, change := range changes {
+		instruction, args, _ := strings.Cut(strings.TrimSpace(change), " ")
+		args = strings.TrimSpace(args)
+		if args == "" {
+			return fmt.Errorf("invalid change %q: missing arguments", change)
+		}
+
+		switch strings.ToUpper(instruction) {
+		case "CMD":
+			cmd, err := parseCmd(args)
+			if err != nil {
+				return fmt.Errorf("invalid change %q: %w", change, err)
+			}
+			config.Cmd = cmd
+		case "ENV":
+			for _, env := range parseKeyValues(args) {
+				config.Env = setEnv(config.Env, env)
+			}
+		case "LABEL":
+			for _, label := range parseKeyValues(args) {
+				key, value, _ := strings.Cut(label, "=")
+				if config.Labels == nil {
+					config.Labels = map[string]string{}
+				}
+				config.Labels[key] = value
+			}
+		default:
+			return fmt.Errorf("invalid change %q: unsupported instruction %s, must be one of CMD, ENV, LABEL", change, instruction)
+		}
+	}
+	return nil
+}
+
+func parseCmd(args string) ([]string, error) {
+	if !strings.HasPrefix(args, "[") {
+		return strings.Fields(args), nil
+	}
+	var cmd []string
+	if err := json.Unmarshal([]byte(args), &cmd); err != nil {
+		return nil, fmt.Errorf("parse json array error: %w", err)
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return cmd, nil
+}
+
+// parseKeyValues 把 k=v k2=v2 或者 k v 转换为 k=v 的形式
+func parseKeyValues(args string) []string {
+	fields := strings.Fields(args)
+	if !strings.Contains(fields[0], "=") {
+		key, value, _ := strings.Cut(args, " ")
+		return []string{key + "=" + strings.TrimSpace(value)}
+	}
+	return fields
+}
+
+func setEnv(envs []string, env string) []string {
+	key, _, _ := strings.Cut(env, "=")
+	for i, e := range envs {
+		if k, _, _ := strings.Cut(e, "="); k == key {
+			envs[i] = env
+			return envs
+		}
+	}
+	return append(envs, env)
+}
@@ -0,0 +1,31 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyChanges(t *testing.T) {
+	config := &Config{Name: "app"}
+	err := ApplyChanges(config, []string{
+		"CMD sleep 1000",
+		"ENV PATH=/bin HOME=/root",
+		"ENV GREETING hello world",
+		"env HOME=/home/app",
+		"LABEL version=1.0 team=infra",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sleep", "1000"}, config.Cmd)
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/home/app", "GREETING=hello world"}, config.Env)
+	assert.Equal(t, map[string]string{"version": "1.0", "team": "infra"}, config.Labels)
+
+	assert.Nil(t, ApplyChanges(config, []string{`CMD ["top", "-b"]`}))
+	assert.Equal(t, []string{"top", "-b"}, config.Cmd)
+}
+
+func TestApplyChangesInvalid(t *testing.T) {
+	for _, change := range []string{"CMD", "CMD []", `CMD ["sh"`, "EXPOSE 80", "ENV"} {
+		assert.NotNil(t, ApplyChanges(&Config{}, []string{change}), change)
+	}
+}
@@ -0,0 +1,58 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/store"
+	"github.com/wangstu/mydocker/utils"
+)
+
+const storeKind = "image"
+
+// Config 镜像的元数据，保存在镜像 tar 包旁边的 <name>.json 中
+type Config struct {
+	store.Meta
+	Name    string            `json:"name"`
+	Created string            `json:"created,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// Cmd、Env 为运行容器时的默认命令和环境变量，通过 import --change 设置
+	Cmd []string `json:"cmd,omitempty"`
+	Env []string `json:"env,omitempty"`
+}
+
+// ReadConfig 读取镜像的元数据，手动放入的 tar 包没有元数据文件，返回只有名称的配置
+func ReadConfig(imageName string) (*Config, error) {
+	config := &Config{Name: imageName}
+	if err := store.ReadJSON(storeKind, utils.GetImageConfigPath(imageName), config); err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("read image %s config error: %w", imageName, err)
+	}
+	return config, nil
+}
+
+// WriteConfig 写入镜像的元数据，没有指定创建时间时使用当前时间
+func WriteConfig(config *Config) error {
+	if config.Created == "" {
+		config.Created = time.Now().Format(container.TimeFormat)
+	}
+	if err := store.WriteJSON(utils.GetImageConfigPath(config.Name), config); err != nil {
+		return fmt.Errorf("write image %s config error: %w", config.Name, err)
+	}
+	return nil
+}
+
+// Remove 删除镜像的 tar 包和元数据
+func Remove(imageName string) error {
+	if err := os.Remove(utils.GetImagePath(imageName)); err != nil {
+		return err
+	}
+	if err := os.Remove(utils.GetImageConfigPath(imageName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -18,14 +18,35 @@ func main() {
 
 	app.Commands = []cli.Command{
 		initCmd,
+		monitorCmd,
 		runCmd,
 		commitCmd,
+		diffCmd,
+		cpCmd,
+		exportCmd,
+		importCmd,
 		listCmd,
 		logCmd,
 		execCmd,
+		topCmd,
+		portCmd,
+		statsCmd,
+		updateCmd,
 		stopCmd,
+		killCmd,
+		startCmd,
+		restartCmd,
+		waitCmd,
+		pauseCmd,
+		unpauseCmd,
+		renameCmd,
 		rmCmd,
+		inspectCmd,
+		eventsCmd,
 		networkCmd,
+		containerCmd,
+		imageCmd,
+		systemCmd,
 	}
 
 	app.Before = func(ctx *cli.Context) error {
@@ -109,19 +109,12 @@ func (d *BridgeNetworkDriver) Disconnect(endpointId string) error {
 		return err
 	}
 
-	// del veth-pair
+	// del veth-pair, the peer in container's net namespace is removed along with it.
+	// NOTE: do not look up the peer by name here, it would match eth0 of the host.
 	err = netlink.LinkDel(veth)
 	if err != nil {
 		return fmt.Errorf("delete veth %s error: %w", vethName, err)
 	}
-	peerVeth, err := netlink.LinkByName(defaultPeerVethName)
-	if err != nil {
-		return fmt.Errorf("get peer veth %s error: %w", defaultPeerVethName, err)
-	}
-	err = netlink.LinkDel(peerVeth)
-	if err != nil {
-		return fmt.Errorf("delete peer veth %s error: %w", defaultPeerVethName, err)
-	}
 	return nil
 }
 
@@ -10,21 +10,57 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/wangstu/mydocker/constant"
+	"github.com/wangstu/mydocker/store"
 )
 
-const ipamDefaultAllocatorPath = "/var/lib/mydocker/network/ipam/subnet.json"
+const (
+	ipamDefaultAllocatorPath = "/var/lib/mydocker/network/ipam/subnet.json"
+	ipamStoreKind            = "ipam"
+)
 
 type IPAM struct {
 	SubnetAllocatorPath string
 	Subnets             map[string]string // 网段和位图算法的数组 map, key 是网段， value 是分配的位图数组
 }
 
+// ipamState subnet.json 中持久化的内容
+type ipamState struct {
+	store.Meta
+	Subnets map[string]string `json:"subnets"`
+}
+
 var ipAllocator = &IPAM{
 	SubnetAllocatorPath: ipamDefaultAllocatorPath,
 }
 
+func init() {
+	// 版本 0 的 subnet.json 直接保存了网段到位图的 map
+	store.RegisterMigration(ipamStoreKind, 0, func(content []byte) ([]byte, error) {
+		state := &ipamState{}
+		if err := json.Unmarshal(content, &state.Subnets); err != nil {
+			return nil, err
+		}
+		return json.Marshal(state)
+	})
+}
+
+// lock 分配和释放 IP 都是先读取再写回位图，需要在锁内完成，避免并发分配出重复的 IP
+func (ipam *IPAM) lock() (*store.Lock, error) {
+	ipamConfigFolder, _ := path.Split(ipam.SubnetAllocatorPath)
+	if err := os.MkdirAll(ipamConfigFolder, constant.Perm0755); err != nil {
+		return nil, err
+	}
+	return store.LockPath(ipam.SubnetAllocatorPath)
+}
+
 // Allocate 在网段中分配一个可用的 IP 地址
 func (ipam *IPAM) Allocate(subnet *net.IPNet) (ip net.IP, err error) {
+	lock, err := ipam.lock()
+	if err != nil {
+		return nil, fmt.Errorf("lock subnet allocation info error: %w", err)
+	}
+	defer lock.Unlock()
+
 	ipam.Subnets = map[string]string{}
 	if err := ipam.load(); err != nil {
 		return nil, fmt.Errorf("load subnet allocation info error: %w", err)
@@ -73,6 +109,12 @@ func (ipam *IPAM) Allocate(subnet *net.IPNet) (ip net.IP, err error) {
 }
 
 func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) error {
+	lock, err := ipam.lock()
+	if err != nil {
+		return fmt.Errorf("lock subnet allocation info error: %w", err)
+	}
+	defer lock.Unlock()
+
 	ipam.Subnets = map[string]string{}
 	if err := ipam.load(); err != nil {
 		return fmt.Errorf("load subnet allocation info error: %w", err)
@@ -92,8 +134,7 @@ func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) error {
 	ipalloc[idx] = '0'
 	ipam.Subnets[subnet.String()] = string(ipalloc)
 
-	err := ipam.dump()
-	if err != nil {
+	if err = ipam.dump(); err != nil {
 		logrus.Errorf("dump ipam subnets error: %v", err)
 	}
 	return err
@@ -109,37 +150,16 @@ func (ipam *IPAM) load() error {
 	}
 
 	//读取文件，加载配置信息
-	content, err := os.ReadFile(ipam.SubnetAllocatorPath)
-	if err != nil {
+	state := &ipamState{}
+	if err := store.ReadJSON(ipamStoreKind, ipam.SubnetAllocatorPath, state); err != nil {
 		return fmt.Errorf("read subnets from config file error: %w", err)
 	}
-	if err = json.Unmarshal(content, &ipam.Subnets); err != nil {
-		return fmt.Errorf("unmarshal subnets error: %w", err)
+	if state.Subnets != nil {
+		ipam.Subnets = state.Subnets
 	}
 	return nil
 }
 
 func (ipam *IPAM) dump() error {
-	ipamConfigFolder, _ := path.Split(ipam.SubnetAllocatorPath)
-	if _, err := os.Stat(ipamConfigFolder); err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-		if err = os.MkdirAll(ipamConfigFolder, constant.Perm0644); err != nil {
-			return err
-		}
-	}
-
-	file, err := os.OpenFile(ipam.SubnetAllocatorPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, constant.Perm0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	subnetsBytes, err := json.MarshalIndent(ipam.Subnets, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal subents error: %w", err)
-	}
-	_, err = file.Write(subnetsBytes)
-	return err
-
+	return store.WriteJSON(ipam.SubnetAllocatorPath, &ipamState{Subnets: ipam.Subnets})
 }
@@ -2,6 +2,9 @@ package network
 
 import (
 	"net"
+	"os"
+	"path"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,4 +21,41 @@ func TestRelease(t *testing.T) {
 	ip, ipNet, _ := net.ParseCIDR("172.24.0.3/24")
 	err := ipAllocator.Release(ipNet, &ip)
 	assert.Nil(t, err)
-}
\ No newline at end of file
+}
+
+func TestAllocateConcurrently(t *testing.T) {
+	allocatorPath := path.Join(t.TempDir(), "subnet.json")
+	_, ipNet, _ := net.ParseCIDR("172.25.0.0/24")
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ips = map[string]bool{}
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 每个 IPAM 实例模拟一个独立的 mydocker 进程
+			ipam := &IPAM{SubnetAllocatorPath: allocatorPath}
+			ip, err := ipam.Allocate(ipNet)
+			assert.Nil(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.False(t, ips[ip.String()], "duplicated ip %s", ip)
+			ips[ip.String()] = true
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, len(ips))
+}
+
+func TestLoadLegacySubnets(t *testing.T) {
+	allocatorPath := path.Join(t.TempDir(), "subnet.json")
+	assert.Nil(t, os.WriteFile(allocatorPath, []byte(`{"172.26.0.0/24": "11"}`), 0644))
+
+	ipam := &IPAM{SubnetAllocatorPath: allocatorPath, Subnets: map[string]string{}}
+	assert.Nil(t, ipam.load())
+	assert.Equal(t, "11", ipam.Subnets["172.26.0.0/24"])
+}
@@ -4,21 +4,27 @@ import (
 	"net"
 
 	"github.com/vishvananda/netlink"
+	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/store"
 )
 
 type Network struct {
+	store.Meta
 	Name    string
 	IPRange *net.IPNet
 	Driver  string
+	// 创建时间，格式为 container.TimeFormat，旧版本创建的网络没有记录
+	CreateTime string            `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
 }
 
 type Endpoint struct {
-	ID          string           `json:"id"`
-	Device      netlink.Veth     `json:"dev"`
-	IPAddress   net.IP           `json:"ip"`
-	MacAddress  net.HardwareAddr `json:"mac"`
-	Network     *Network
-	PortMapping []string
+	ID         string           `json:"id"`
+	Device     netlink.Veth     `json:"dev"`
+	IPAddress  net.IP           `json:"ip"`
+	MacAddress net.HardwareAddr `json:"mac"`
+	Network    *Network
+	Ports      []container.PortBinding
 }
 
 type Driver interface {
@@ -1,7 +1,6 @@
 package network
 
 import (
-	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -9,16 +8,21 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
-	"text/tabwriter"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 	"github.com/wangstu/mydocker/constant"
 	"github.com/wangstu/mydocker/container"
+	"github.com/wangstu/mydocker/events"
+	"github.com/wangstu/mydocker/store"
 )
 
+const networkStoreKind = "network"
+
 var (
 	defaultNetworkPath = "/var/lib/mydocker/network/network/"
 	drivers            = map[string]Driver{}
@@ -41,28 +45,11 @@ func init() {
 }
 
 func (net *Network) dump(dumpPath string) error {
-	if _, err := os.Stat(dumpPath); err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-		if err = os.MkdirAll(dumpPath, constant.Perm0644); err != nil {
-			return fmt.Errorf("create network dump path %s error: %w", defaultNetworkPath, err)
-		}
-	}
-
 	netPath := path.Join(dumpPath, net.Name)
-	netFile, err := os.OpenFile(netPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, constant.Perm0644)
-	if err != nil {
-		return fmt.Errorf("open file %s error: %w", netPath, err)
+	if err := store.WriteJSON(netPath, net); err != nil {
+		return fmt.Errorf("dump network %s error: %w", net.Name, err)
 	}
-	defer netFile.Close()
-
-	netJson, err := json.MarshalIndent(net, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal network error: %w", err)
-	}
-	_, err = netFile.Write(netJson)
-	return err
+	return nil
 }
 
 func (net *Network) remove(dumpPath string) error {
@@ -77,11 +64,17 @@ func (net *Network) remove(dumpPath string) error {
 }
 
 func (net *Network) load(netPath string) error {
-	content, err := os.ReadFile(netPath)
-	if err != nil {
-		return err
+	return store.ReadJSON(networkStoreKind, netPath, net)
+}
+
+// logEvent 记录网络事件，属性中包括网络的 label
+func (net *Network) logEvent(action string, attributes map[string]string) {
+	attrs := map[string]string{}
+	events.AddLabels(attrs, net.Labels)
+	for k, v := range attributes {
+		attrs[k] = v
 	}
-	return json.Unmarshal(content, net)
+	events.Log(events.TypeNetwork, action, net.Name, attrs)
 }
 
 func loadNetworks() (map[string]*Network, error) {
@@ -91,6 +84,10 @@ func loadNetworks() (map[string]*Network, error) {
 			return nil
 		}
 		_, netName := path.Split(netPath)
+		// 跳过写入过程中的临时文件和锁文件
+		if store.IsHidden(netName) {
+			return nil
+		}
 		net := &Network{
 			Name: netName,
 		}
@@ -103,7 +100,50 @@ func loadNetworks() (map[string]*Network, error) {
 	return networks, err
 }
 
-func CreateNetwork(driver, subnet, name string) error {
+// GetNetwork 读取指定名称网络的持久化信息
+func GetNetwork(name string) (*Network, error) {
+	networks, err := loadNetworks()
+	if err != nil {
+		return nil, err
+	}
+	net, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("no such network: %s", name)
+	}
+	return net, nil
+}
+
+// GetNetworks 读取所有网络的持久化信息，按名称排序
+func GetNetworks() ([]*Network, error) {
+	networks, err := loadNetworks()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Network, 0, len(networks))
+	for _, net := range networks {
+		result = append(result, net)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func CreateNetwork(driver, subnet, name string, labels map[string]string) error {
+	lock, err := store.LockGlobal()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	networks, err := loadNetworks()
+	if err != nil {
+		return err
+	}
+	if _, ok := networks[name]; ok {
+		return fmt.Errorf("network %s already exists", name)
+	}
+
 	_, cidr, _ := net.ParseCIDR(subnet)
 	ip, err := ipAllocator.Allocate(cidr)
 	if err != nil {
@@ -115,28 +155,22 @@ func CreateNetwork(driver, subnet, name string) error {
 	if err != nil {
 		return err
 	}
-	return net.dump(defaultNetworkPath)
+	net.CreateTime = time.Now().Format(container.TimeFormat)
+	net.Labels = labels
+	if err = net.dump(defaultNetworkPath); err != nil {
+		return err
+	}
+	net.logEvent("create", map[string]string{"driver": driver})
+	return nil
 }
 
-func ListNetwork() {
-	networks, err := loadNetworks()
+func DeleteNetwork(networkName string) error {
+	lock, err := store.LockGlobal()
 	if err != nil {
-		logrus.Errorf("load networks from file error: %v", err)
-		return
-	}
-	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	fmt.Fprint(w, "NAME\tIpRange\tDriver\n")
-	for _, net := range networks {
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
-			net.Name,
-			net.IPRange.String(),
-			net.Driver,
-		)
+		return err
 	}
-	w.Flush()
-}
+	defer lock.Unlock()
 
-func DeleteNetwork(networkName string) error {
 	networks, err := loadNetworks()
 	if err != nil {
 		return err
@@ -153,10 +187,14 @@ func DeleteNetwork(networkName string) error {
 	if err = drivers[net.Driver].Delete(net); err != nil {
 		return fmt.Errorf("remove network error: %w", err)
 	}
-	return net.remove(defaultNetworkPath)
+	if err = net.remove(defaultNetworkPath); err != nil {
+		return err
+	}
+	net.logEvent("rm", map[string]string{"driver": net.Driver})
+	return nil
 }
 
-func Connect(networkName string, info *container.Info) (net.IP, error) {
+func Connect(networkName string, info *container.Info) (_ net.IP, err error) {
 	networks, err := loadNetworks()
 	if err != nil {
 		return nil, err
@@ -167,30 +205,58 @@ func Connect(networkName string, info *container.Info) (net.IP, error) {
 		return nil, fmt.Errorf("not found %s", networkName)
 	}
 
-	// allocate container ip
-	ip, err := ipAllocator.Allocate(network.IPRange)
+	// allocate container ip, a restarted container keeps the ip reserved for it
+	var ip net.IP
+	if info.IP != "" {
+		ip = net.ParseIP(info.IP).To4()
+	} else {
+		if ip, err = ipAllocator.Allocate(network.IPRange); err != nil {
+			return nil, fmt.Errorf("allocate ip error: %w", err)
+		}
+		// info.IP is only set on success, release the newly allocated ip here or it leaks
+		defer func() {
+			if err == nil {
+				return
+			}
+			if releaseErr := ipAllocator.Release(network.IPRange, &ip); releaseErr != nil {
+				logrus.Warnf("release ip %s error: %v", ip, releaseErr)
+			}
+		}()
+	}
+
+	ports, err := reservePortBindings(info)
 	if err != nil {
-		return ip, fmt.Errorf("allocate ip error: %w", err)
+		return nil, err
 	}
+	defer func() {
+		if err != nil && ports != nil {
+			releasePortBindings(info.Id)
+		}
+	}()
 
 	// create network endpoint
 	ep := &Endpoint{
-		ID:          fmt.Sprintf("%s-%s", info.Id, networkName),
-		IPAddress:   ip,
-		Network:     network,
-		PortMapping: info.PortMapping,
+		ID:        fmt.Sprintf("%s-%s", info.Id, networkName),
+		IPAddress: ip,
+		Network:   network,
+		Ports:     ports,
 	}
 	if err = drivers[network.Driver].Connect(network.Name, ep); err != nil {
-		return ip, err
+		return nil, err
 	}
 
 	// configure container ip
 	if err = configureEndpointIpAddressAndRoute(ep, info); err != nil {
-		return ip, err
+		return nil, err
 	}
 
 	// configure port mapping
-	return ip, addPortMapping(ep)
+	if err = addPortMapping(ep); err != nil {
+		return nil, err
+	}
+	info.Ports = ports
+	network.logEvent("connect", map[string]string{"container": info.Id, "ip": ip.String()})
+	return ip, nil
 }
 
 func Disconnect(info *container.Info) error {
@@ -205,15 +271,44 @@ func Disconnect(info *container.Info) error {
 		return fmt.Errorf("not found %s", networkName)
 	}
 
-	drivers[network.Driver].Disconnect(fmt.Sprintf("%s-%s", info.Id, networkName))
+	if err = drivers[network.Driver].Disconnect(fmt.Sprintf("%s-%s", info.Id, networkName)); err != nil {
+		logrus.Warnf("disconnect endpoint error: %v", err)
+	}
 
+	// 旧版本启动的容器没有记录 Ports，按照 PortMapping 删除规则
+	ports := info.Ports
+	if ports == nil {
+		ports = parsePortBindings(info.PortMapping)
+	}
 	ep := &Endpoint{
-		ID:          fmt.Sprintf("%s-%s", info.Id, networkName),
-		IPAddress:   net.ParseIP(info.IP),
-		Network:     network,
-		PortMapping: info.PortMapping,
+		ID:        fmt.Sprintf("%s-%s", info.Id, networkName),
+		IPAddress: net.ParseIP(info.IP),
+		Network:   network,
+		Ports:     ports,
+	}
+	if err = deletePortMapping(ep); err != nil {
+		logrus.Warnf("%v", err)
 	}
-	return deletePortMapping(ep)
+	network.logEvent("disconnect", map[string]string{"container": info.Id})
+	return nil
+}
+
+// ReleaseIP 释放容器占用的 IP，容器被删除时调用
+func ReleaseIP(info *container.Info) error {
+	if info.IP == "" {
+		return nil
+	}
+	networks, err := loadNetworks()
+	if err != nil {
+		return err
+	}
+
+	network, ok := networks[info.NetworkName]
+	if !ok {
+		return fmt.Errorf("not found %s", info.NetworkName)
+	}
+	ip := net.ParseIP(info.IP).To4()
+	return ipAllocator.Release(network.IPRange, &ip)
 }
 
 func configureEndpointIpAddressAndRoute(ep *Endpoint, info *container.Info) error {
@@ -254,43 +349,206 @@ func configureEndpointIpAddressAndRoute(ep *Endpoint, info *container.Info) erro
 	return netlink.RouteAdd(defaultRoute)
 }
 
+// addPortMapping 按顺序添加 DNAT 规则，出错时删除已经添加的规则
 func addPortMapping(ep *Endpoint) error {
-	return configPortMapping(ep, false)
+	for i, port := range ep.Ports {
+		if err := configPortMapping(ep, port, false); err != nil {
+			for _, added := range ep.Ports[:i] {
+				if deleteErr := configPortMapping(ep, added, true); deleteErr != nil {
+					logrus.Warnf("delete port mapping %s error: %v", added, deleteErr)
+				}
+			}
+			return fmt.Errorf("add port mapping %s error: %w", port, err)
+		}
+	}
+	return nil
 }
 
+// deletePortMapping 删除所有的 DNAT 规则，某一条删除失败时继续删除其他的规则
 func deletePortMapping(ep *Endpoint) error {
-	return configPortMapping(ep, true)
+	var err error
+	for _, port := range ep.Ports {
+		if deleteErr := configPortMapping(ep, port, true); deleteErr != nil {
+			err = fmt.Errorf("delete port mapping %s error: %w", port, deleteErr)
+		}
+	}
+	return err
 }
 
-func configPortMapping(ep *Endpoint, isDelete bool) (err error) {
+func configPortMapping(ep *Endpoint, port container.PortBinding, isDelete bool) error {
 	action := "-A"
 	if isDelete {
 		action = "-D"
 	}
-	for _, pm := range ep.PortMapping {
-		pms := strings.Split(pm, ":")
-		if len(pms) != 2 {
-			logrus.Errorf("port mapping format error: %v", pm)
+	// 由于iptables没有Go语言版本的实现，所以采用exec.Command的方式直接调用命令配置
+	// 在iptables的PREROUTING中添加DNAT规则
+	// 将宿主机的端口请求转发到容器的地址和端口上
+	// iptables -t nat -A PREROUTING ! -i testbridge -p tcp -m tcp --dport 8080 -j DNAT --to-destination 10.0.0.4:8
+	// 指定了宿主机 IP 时只转发发往该地址的请求
+	destination := ""
+	if port.HostIP != "" {
+		destination = " -d " + port.HostIP
+	}
+	iptablesCmd := fmt.Sprintf("-t nat %s PREROUTING ! -i %s%s -p %s -m %s --dport %d -j DNAT --to-destination %s:%d",
+		action,
+		ep.Network.Name,
+		destination,
+		port.Protocol,
+		port.Protocol,
+		port.HostPort,
+		ep.IPAddress.String(),
+		port.ContainerPort)
+	cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
+	logrus.Infof("DNAT cmd: %v", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logrus.Errorf("iptable output: %v", string(output))
+	}
+	return err
+}
+
+// hostPort 宿主机上被占用的端口，不区分宿主机 IP，0.0.0.0 上的映射和所有地址冲突
+type hostPort struct {
+	port     int
+	protocol string
+}
+
+// reservePortBindings 在全局锁内为容器分配端口并写入容器信息
+// DNAT 规则不占用 socket，内核分配的空闲端口可能已经映射给了其他容器，需要和其他容器记录的 Ports 比较
+func reservePortBindings(info *container.Info) ([]container.PortBinding, error) {
+	if len(info.PortMapping) == 0 {
+		return nil, nil
+	}
+	lock, err := store.LockGlobal()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	used, err := usedHostPorts(info.Id)
+	if err != nil {
+		return nil, err
+	}
+	ports, err := allocatePortBindings(info.PortMapping, used)
+	if err != nil {
+		return nil, err
+	}
+	// 释放全局锁之前写入容器信息，其他容器分配端口时才能看到
+	_, err = container.ModifyContainerInfo(info.Id, func(containerInfo *container.Info) error {
+		containerInfo.Ports = ports
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record port bindings error: %w", err)
+	}
+	return ports, nil
+}
+
+// releasePortBindings 连接网络失败时清除 reservePortBindings 记录的端口
+func releasePortBindings(containerId string) {
+	_, err := container.ModifyContainerInfo(containerId, func(containerInfo *container.Info) error {
+		containerInfo.Ports = nil
+		return nil
+	})
+	if err != nil {
+		logrus.Warnf("release port bindings of container %s error: %v", containerId, err)
+	}
+}
+
+// usedHostPorts 返回除 containerId 之外的容器已经映射的宿主机端口，容器退出时会清空 Ports
+func usedHostPorts(containerId string) (map[hostPort]bool, error) {
+	used := map[hostPort]bool{}
+	entries, err := os.ReadDir(container.InfoLoc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return used, nil
+		}
+		return nil, fmt.Errorf("read dir %s error: %w", container.InfoLoc, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || store.IsHidden(entry.Name()) || entry.Name() == containerId {
+			continue
+		}
+		info, err := container.ReadContainerInfo(entry.Name())
+		if err != nil {
+			logrus.Warnf("get container %s info error: %v", entry.Name(), err)
 			continue
 		}
-		// 由于iptables没有Go语言版本的实现，所以采用exec.Command的方式直接调用命令配置
-		// 在iptables的PREROUTING中添加DNAT规则
-		// 将宿主机的端口请求转发到容器的地址和端口上
-		// iptables -t nat -A PREROUTING ! -i testbridge -p tcp -m tcp --dport 8080 -j DNAT --to-destination 10.0.0.4:8
-		iptablesCmd := fmt.Sprintf("-t nat %s PREROUTING ! -i %s -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
-			action,
-			ep.Network.Name,
-			pms[0],
-			ep.IPAddress.String(),
-			pms[1])
-		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
-		logrus.Infof("DNAT cmd: %v", cmd)
-		output, err := cmd.Output()
+		for _, port := range info.Ports {
+			used[hostPort{port: port.HostPort, protocol: port.Protocol}] = true
+		}
+	}
+	return used, nil
+}
+
+// maxHostPortAttempts 内核分配的端口已经被其他容器映射时重新分配的次数
+const maxHostPortAttempts = 10
+
+// allocatePortBindings 解析 -p 参数，没有指定宿主机端口时由内核分配一个当前空闲并且没有被 used 占用的端口
+func allocatePortBindings(portMapping []string, used map[hostPort]bool) ([]container.PortBinding, error) {
+	var ports []container.PortBinding
+	for _, pm := range portMapping {
+		port, err := container.ParsePortMapping(pm)
 		if err != nil {
-			logrus.Errorf("iptable output: %v", string(output))
+			return nil, err
+		}
+		if port.HostPort != 0 {
+			if used[hostPort{port: port.HostPort, protocol: port.Protocol}] {
+				return nil, fmt.Errorf("host port %d/%s is already allocated", port.HostPort, port.Protocol)
+			}
+		} else if port.HostPort, err = allocateHostPort(port.HostIP, port.Protocol, used); err != nil {
+			return nil, fmt.Errorf("allocate host port for %s error: %w", pm, err)
 		}
+		used[hostPort{port: port.HostPort, protocol: port.Protocol}] = true
+		ports = append(ports, *port)
 	}
-	return err
+	return ports, nil
+}
+
+func allocateHostPort(hostIP, protocol string, used map[hostPort]bool) (int, error) {
+	for i := 0; i < maxHostPortAttempts; i++ {
+		port, err := listenFreePort(hostIP, protocol)
+		if err != nil {
+			return 0, err
+		}
+		if !used[hostPort{port: port, protocol: protocol}] {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free %s port after %d attempts", protocol, maxHostPortAttempts)
+}
+
+// listenFreePort 监听 0 端口，由内核分配一个当前没有 socket 占用的端口
+func listenFreePort(hostIP, protocol string) (int, error) {
+	addr := net.JoinHostPort(hostIP, "0")
+	if protocol == container.ProtocolUDP {
+		conn, err := net.ListenPacket("udp4", addr)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port, nil
+	}
+	listener, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// parsePortBindings 解析旧版本容器记录的 PortMapping，跳过格式错误和没有宿主机端口的映射
+func parsePortBindings(portMapping []string) []container.PortBinding {
+	var ports []container.PortBinding
+	for _, pm := range portMapping {
+		port, err := container.ParsePortMapping(pm)
+		if err != nil || port.HostPort == 0 {
+			logrus.Errorf("port mapping format error: %v", pm)
+			continue
+		}
+		ports = append(ports, *port)
+	}
+	return ports
 }
 
 // enterContainerNetNS 将容器的网络端点加入到容器的网络空间中
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wangstu/mydocker/container"
+)
+
+func TestAllocatePortBindings(t *testing.T) {
+	ports, err := allocatePortBindings([]string{"8080:80", "127.0.0.1::53/udp"}, map[hostPort]bool{})
+	assert.Nil(t, err)
+	assert.Len(t, ports, 2)
+	assert.Equal(t, container.PortBinding{HostPort: 8080, ContainerPort: 80, Protocol: container.ProtocolTCP}, ports[0])
+	assert.Equal(t, "127.0.0.1", ports[1].HostIP)
+	assert.NotZero(t, ports[1].HostPort)
+	assert.Equal(t, 53, ports[1].ContainerPort)
+
+	_, err = allocatePortBindings([]string{"8080:80:90:100"}, map[hostPort]bool{})
+	assert.NotNil(t, err)
+
+	used := map[hostPort]bool{{port: 8080, protocol: container.ProtocolTCP}: true}
+	_, err = allocatePortBindings([]string{"8080:80"}, used)
+	assert.NotNil(t, err)
+	ports, err = allocatePortBindings([]string{"8080:80/udp", "80"}, used)
+	assert.Nil(t, err)
+	assert.Equal(t, 8080, ports[0].HostPort)
+	assert.NotEqual(t, 8080, ports[1].HostPort)
+	assert.True(t, used[hostPort{port: ports[1].HostPort, protocol: container.ProtocolTCP}])
+}
+
+func TestParsePortBindings(t *testing.T) {
+	ports := parsePortBindings([]string{"8080:80", "9000", "bad"})
+	assert.Equal(t, []container.PortBinding{{HostPort: 8080, ContainerPort: 80, Protocol: container.ProtocolTCP}}, ports)
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InterfaceStats 网卡收发的字节数
+type InterfaceStats struct {
+	RxBytes uint64
+	TxBytes uint64
+}
+
+// GetContainerStats 读取容器 network namespace 中除 lo 之外所有网卡（即 veth 在容器中的一端）的流量
+func GetContainerStats(pid int) (*InterfaceStats, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return nil, fmt.Errorf("read network stats error: %w", err)
+	}
+	return parseNetDev(content)
+}
+
+// parseNetDev 解析 /proc/net/dev，前两行为表头，之后每行为 "iface: rx_bytes rx_packets ... tx_bytes ..."
+func parseNetDev(content []byte) (*InterfaceStats, error) {
+	stats := &InterfaceStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		name, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("invalid network stats of %s", strings.TrimSpace(name))
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rx bytes of %s: %w", strings.TrimSpace(name), err)
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx bytes of %s: %w", strings.TrimSpace(name), err)
+		}
+		stats.RxBytes += rx
+		stats.TxBytes += tx
+	}
+	return stats, scanner.Err()
+}
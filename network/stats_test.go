@@ -0,0 +1,21 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetDev(t *testing.T) {
+	content := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     560       8    0    0    0     0          0         0      560       8    0    0    0     0       0          0
+cif-abcde:   1296      16    0    0    0     0          0         0      726       9    0    0    0     0       0          0
+`
+	stats, err := parseNetDev([]byte(content))
+	assert.Nil(t, err)
+	assert.Equal(t, &InterfaceStats{RxBytes: 1296, TxBytes: 726}, stats)
+
+	_, err = parseNetDev([]byte("eth0: 1 2 3\n"))
+	assert.NotNil(t, err)
+}
@@ -9,12 +9,15 @@ package nsenter
 #include <stdlib.h>
 #include <string.h>
 #include <fcntl.h>
+#include <sys/wait.h>
 __attribute__((constructor)) void enter_namespace(void) {
     // 这里的代码会在Go运行时启动前执行，它会在单线程的C上下文中运行
 	char *mydocker_pid;
 	mydocker_pid = getenv("mydocker_pid");
+	// 设置了 mydocker_quiet 时不输出调试信息，比如健康检查需要拿到命令本身的输出
+	int verbose = getenv("mydocker_quiet") == NULL;
 	if (mydocker_pid) {
-		fprintf(stdout, "got mydocker_pid=%s\n", mydocker_pid);
+		if (verbose) fprintf(stdout, "got mydocker_pid=%s\n", mydocker_pid);
 	} else {
 		fprintf(stdout, "missing mydocker_pid env skip nsenter");
 		// 如果没有指定PID就不需要继续执行，直接退出
@@ -23,7 +26,7 @@ __attribute__((constructor)) void enter_namespace(void) {
 	char *mydocker_cmd;
 	mydocker_cmd = getenv("mydocker_cmd");
 	if (mydocker_cmd) {
-		fprintf(stdout, "got mydocker_cmd=%s\n", mydocker_cmd);
+		if (verbose) fprintf(stdout, "got mydocker_cmd=%s\n", mydocker_cmd);
 	} else {
 		fprintf(stdout, "missing mydocker_cmd env skip nsenter");
 		// 如果没有指定命令也是直接退出
@@ -40,14 +43,22 @@ __attribute__((constructor)) void enter_namespace(void) {
 		// 执行setns系统调用，进入对应namespace
 		if (setns(fd, 0) == -1) {
 			fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
-		} else {
+			// 容器进程可能已经退出，不能在宿主机的 namespace 中执行命令
+			exit(1);
+		} else if (verbose) {
 			fprintf(stdout, "setns on %s namespace succeeded\n", namespaces[i]);
 		}
 		close(fd);
 	}
-	// 在进入的Namespace中执行指定命令，然后退出
+	// 在进入的Namespace中执行指定命令，然后以命令的退出码退出
 	int res = system(mydocker_cmd);
-	exit(0);
+	if (res == -1) {
+		exit(127);
+	}
+	if (WIFSIGNALED(res)) {
+		exit(128 + WTERMSIG(res));
+	}
+	exit(WEXITSTATUS(res));
 	return;
 }
 */
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+// globalLockPath 全局锁，保护跨对象的操作，比如容器名称唯一性检查、网络的创建和删除
+const globalLockPath = "/var/lib/mydocker/global"
+
+// Lock 基于 flock 的进程间排它锁，进程退出时内核会自动释放
+type Lock struct {
+	file *os.File
+}
+
+// LockPath 对 p 对应的对象加排它锁，锁文件为同目录下的 .<name>.lock，阻塞直到获取到锁
+// 所在目录需要由调用方保证存在，这样对象被删除后不会因为加锁又把目录创建出来
+// NOTE: flock 作用于打开的文件描述符，同一个进程重复加锁也会阻塞，不能嵌套调用
+func LockPath(p string) (*Lock, error) {
+	dir, base := filepath.Split(p)
+	lockPath := filepath.Join(dir, "."+base+".lock")
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, constant.Perm0644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file %s error: %w", lockPath, err)
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("lock %s error: %w", lockPath, err)
+	}
+	return &Lock{file: file}, nil
+}
+
+// LockGlobal 获取全局锁
+func LockGlobal() (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(globalLockPath), constant.Perm0755); err != nil {
+		return nil, err
+	}
+	return LockPath(globalLockPath)
+}
+
+// Unlock 释放锁
+func (l *Lock) Unlock() error {
+	defer l.file.Close()
+	return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
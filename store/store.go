@@ -0,0 +1,110 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wangstu/mydocker/constant"
+)
+
+// SchemaVersion 当前持久化数据的版本，数据结构发生不兼容的变化时递增，并注册对应的迁移函数
+const SchemaVersion = 1
+
+// Meta 需要持久化的结构体嵌入 Meta，写入时由 store 填充版本号
+type Meta struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+func (m *Meta) setSchemaVersion(version int) {
+	m.SchemaVersion = version
+}
+
+type versioned interface {
+	setSchemaVersion(version int)
+}
+
+// Migration 将某个版本的原始 JSON 内容升级到下一个版本
+type Migration func(content []byte) ([]byte, error)
+
+// migrations kind -> from version -> migration
+var migrations = map[string]map[int]Migration{}
+
+// RegisterMigration 注册 kind 类型数据从 from 版本升级到 from+1 版本的迁移函数
+func RegisterMigration(kind string, from int, migration Migration) {
+	if migrations[kind] == nil {
+		migrations[kind] = map[int]Migration{}
+	}
+	migrations[kind][from] = migration
+}
+
+// ReadJSON 读取 p 中的 JSON 数据，按 kind 注册的迁移函数升级到当前版本后解析到 v 中
+// 没有版本号的数据视为版本 0，没有注册迁移函数的版本直接跳过
+func ReadJSON(kind, p string, v interface{}) error {
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	meta := Meta{}
+	// 版本 0 的数据不一定是 JSON 对象，解析失败时同样视为版本 0
+	_ = json.Unmarshal(content, &meta)
+	for version := meta.SchemaVersion; version < SchemaVersion; version++ {
+		migration, ok := migrations[kind][version]
+		if !ok {
+			continue
+		}
+		if content, err = migration(content); err != nil {
+			return fmt.Errorf("migrate %s %s from version %d error: %w", kind, p, version, err)
+		}
+	}
+	return json.Unmarshal(content, v)
+}
+
+// WriteJSON 原子地将 v 写入 p：先写入同目录下的临时文件并 fsync，再 rename 覆盖目标文件，
+// 这样进程在写入过程中崩溃或者并发读取时都不会看到写了一半的文件
+func WriteJSON(p string, v interface{}) error {
+	if obj, ok := v.(versioned); ok {
+		obj.setSchemaVersion(SchemaVersion)
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s error: %w", p, err)
+	}
+
+	dir, base := filepath.Split(p)
+	if err = os.MkdirAll(dir, constant.Perm0755); err != nil {
+		return fmt.Errorf("mkdir %s error: %w", dir, err)
+	}
+	tmpFile, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err = tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("write %s error: %w", tmpPath, err)
+	}
+	if err = tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("sync %s error: %w", tmpPath, err)
+	}
+	if err = tmpFile.Close(); err != nil {
+		return fmt.Errorf("close %s error: %w", tmpPath, err)
+	}
+	if err = os.Chmod(tmpPath, constant.Perm0644); err != nil {
+		return fmt.Errorf("chmod %s error: %w", tmpPath, err)
+	}
+	if err = os.Rename(tmpPath, p); err != nil {
+		return fmt.Errorf("rename %s to %s error: %w", tmpPath, p, err)
+	}
+	return nil
+}
+
+// IsHidden store 在数据目录中创建的临时文件和锁文件都以 . 开头，遍历目录时需要跳过
+func IsHidden(name string) bool {
+	return len(name) > 0 && name[0] == '.'
+}
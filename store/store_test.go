@@ -0,0 +1,73 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testObject struct {
+	Meta
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestWriteAndReadJSON(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "obj.json")
+	assert.Nil(t, WriteJSON(p, &testObject{Name: "test"}))
+
+	obj := &testObject{}
+	assert.Nil(t, ReadJSON("test", p, obj))
+	assert.Equal(t, "test", obj.Name)
+	assert.Equal(t, SchemaVersion, obj.SchemaVersion)
+
+	// 临时文件不会残留在目录中
+	entries, _ := os.ReadDir(filepath.Dir(p))
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestMigration(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "legacy.json")
+	assert.Nil(t, os.WriteFile(p, []byte(`"legacy"`), 0644))
+
+	RegisterMigration("test-legacy", 0, func(content []byte) ([]byte, error) {
+		var name string
+		if err := json.Unmarshal(content, &name); err != nil {
+			return nil, err
+		}
+		return json.Marshal(&testObject{Name: name})
+	})
+	obj := &testObject{}
+	assert.Nil(t, ReadJSON("test-legacy", p, obj))
+	assert.Equal(t, "legacy", obj.Name)
+}
+
+func TestLockPath(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "counter.json")
+	assert.Nil(t, WriteJSON(p, &testObject{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := LockPath(p)
+			assert.Nil(t, err)
+			defer lock.Unlock()
+
+			obj := &testObject{}
+			assert.Nil(t, ReadJSON("test", p, obj))
+			obj.Count++
+			assert.Nil(t, WriteJSON(p, obj))
+		}()
+	}
+	wg.Wait()
+
+	obj := &testObject{}
+	assert.Nil(t, ReadJSON("test", p, obj))
+	assert.Equal(t, 20, obj.Count)
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+)
 
 func IsPathExist(p string) (bool, error) {
 	if _, err := os.Stat(p); err != nil {
@@ -11,3 +14,21 @@ func IsPathExist(p string) (bool, error) {
 	}
 	return true, nil
 }
+
+// DirSize 统计目录下所有普通文件的大小，目录不存在时返回 0
+func DirSize(p string) (int64, error) {
+	var size int64
+	err := filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ParseLabels 解析 --label-file 和 --label 参数，--label 中的值会覆盖文件中同名的 label，没有指定时返回 nil
+/*
+格式和 docker 一致：
+1）每个 label 为 key=value，只有 key 时 value 为空
+2）label 文件中每行一个 label，忽略空行和 # 开头的注释
+*/
+func ParseLabels(labels, labelFiles []string) (map[string]string, error) {
+	var lines []string
+	for _, file := range labelFiles {
+		fileLines, err := readLabelFile(file)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fileLines...)
+	}
+	lines = append(lines, labels...)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(lines))
+	for _, line := range lines {
+		key, value, _ := strings.Cut(line, "=")
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid label %s, must be key=value", line)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func readLabelFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open label file %s error: %w", file, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read label file %s error: %w", file, err)
+	}
+	return lines, nil
+}
+
+// MergeLabels 合并两组 label，overrides 中同名的 label 优先，都为空时返回 nil
+func MergeLabels(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overrides {
+		result[k] = v
+	}
+	return result
+}
+
+// MatchLabel 判断是否满足 label 过滤条件，条件为 key 时只要求存在该 label，为 key=value 时还要求值相等
+func MatchLabel(labels map[string]string, filter string) bool {
+	key, value, hasValue := strings.Cut(filter, "=")
+	actual, ok := labels[key]
+	if !ok {
+		return false
+	}
+	return !hasValue || actual == value
+}
+
+// FormatLabels 按 key 排序后输出为 k1=v1,k2=v2
+func FormatLabels(labels map[string]string) string {
+	kvs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
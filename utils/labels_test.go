@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, labels)
+
+	file := filepath.Join(t.TempDir(), "labels")
+	content := "# owner of the container\nowner=alice\n\nticket=OPS-1\nempty\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	labels, err = ParseLabels([]string{"owner=bob", "url=http://a?b=c"}, []string{file})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"owner":  "bob",
+		"ticket": "OPS-1",
+		"empty":  "",
+		"url":    "http://a?b=c",
+	}, labels)
+
+	_, err = ParseLabels([]string{"=value"}, nil)
+	assert.Error(t, err)
+	_, err = ParseLabels(nil, []string{filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
+
+func TestMergeLabels(t *testing.T) {
+	assert.Nil(t, MergeLabels(nil, map[string]string{}))
+	assert.Equal(t, map[string]string{"owner": "bob", "ticket": "OPS-1"},
+		MergeLabels(map[string]string{"owner": "alice", "ticket": "OPS-1"}, map[string]string{"owner": "bob"}))
+}
+
+func TestMatchLabel(t *testing.T) {
+	labels := map[string]string{"owner": "alice", "empty": ""}
+	assert.True(t, MatchLabel(labels, "owner"))
+	assert.True(t, MatchLabel(labels, "owner=alice"))
+	assert.False(t, MatchLabel(labels, "owner=bob"))
+	assert.True(t, MatchLabel(labels, "empty="))
+	assert.False(t, MatchLabel(labels, "ticket"))
+	assert.False(t, MatchLabel(nil, "owner"))
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", FormatLabels(nil))
+	assert.Equal(t, "a=1,b=", FormatLabels(map[string]string{"b": "", "a": "1"}))
+}
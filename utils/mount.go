@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const mountPointIndex = 4
+
+// IsMountPoint 通过 /proc/self/mountinfo 判断 p 是否已经是一个挂载点
+func IsMountPoint(p string) (bool, error) {
+	mountPoints, err := readMountPoints()
+	if err != nil {
+		return false, err
+	}
+	target := filepath.Clean(p)
+	for _, mountPoint := range mountPoints {
+		if mountPoint == target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetMountPointsUnder 返回 dir 以及其中所有的挂载点，按照先子目录后父目录的顺序排列，可以直接按顺序卸载
+func GetMountPointsUnder(dir string) ([]string, error) {
+	mountPoints, err := readMountPoints()
+	if err != nil {
+		return nil, err
+	}
+	return filterMountPoints(mountPoints, dir), nil
+}
+
+func filterMountPoints(mountPoints []string, dir string) []string {
+	dir = filepath.Clean(dir)
+	var result []string
+	for _, mountPoint := range mountPoints {
+		if mountPoint == dir || strings.HasPrefix(mountPoint, dir+"/") {
+			result = append(result, mountPoint)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] > result[j]
+	})
+	return result
+}
+
+func readMountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountPoints(f)
+}
+
+func parseMountPoints(r io.Reader) ([]string, error) {
+	var mountPoints []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// 104 85 0:20 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime - cgroup cgroup rw,memory
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > mountPointIndex {
+			mountPoints = append(mountPoints, unescapeMountPoint(fields[mountPointIndex]))
+		}
+	}
+	return mountPoints, scanner.Err()
+}
+
+// unescapeMountPoint mountinfo 中路径里的空格、制表符等字符被转义为 \040 这样的八进制形式
+func unescapeMountPoint(p string) string {
+	if !strings.Contains(p, `\`) {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+3 < len(p) {
+			if c, err := strconv.ParseUint(p[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
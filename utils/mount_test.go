@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMountPointsUnder(t *testing.T) {
+	mountinfo := `22 1 0:20 / / rw,relatime - ext4 /dev/vda rw
+85 22 0:45 / /var/lib/mydocker/overlay2/abc/merged rw,relatime - overlay overlay rw
+86 85 8:1 /data /var/lib/mydocker/overlay2/abc/merged/data rw,relatime - ext4 /dev/vda rw
+87 85 8:1 /my\040dir /var/lib/mydocker/overlay2/abc/merged/my\040dir rw,relatime - ext4 /dev/vda rw
+88 22 0:46 / /var/lib/mydocker/overlay2/abcd/merged rw,relatime - overlay overlay rw
+`
+	mountPoints, err := parseMountPoints(strings.NewReader(mountinfo))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/var/lib/mydocker/overlay2/abc/merged/my dir",
+		"/var/lib/mydocker/overlay2/abc/merged/data",
+		"/var/lib/mydocker/overlay2/abc/merged",
+	}, filterMountPoints(mountPoints, "/var/lib/mydocker/overlay2/abc/"))
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// starttime 是 /proc/<pid>/stat 中的第 22 个字段，去掉 pid 和 comm 之后位于第 20 个
+	startTimeIndex = 19
+	// utime、stime 是第 14、15 个字段
+	utimeIndex = 11
+	stimeIndex = 12
+
+	// clockTicks 即 sysconf(_SC_CLK_TCK)，Linux 上总是 100
+	clockTicks = 100
+)
+
+// ProcessStatus /proc/<pid>/status 中的进程信息
+type ProcessStatus struct {
+	Name string
+	Pid  int
+	// Tgid 线程所属的进程
+	Tgid int
+	PPid int
+	// NSPid 进程在自己所在的（最内层）pid namespace 中的 pid
+	NSPid int
+	Uid   int
+	// RSS 常驻内存大小，单位为字节，内核线程没有该字段
+	RSS int64
+}
+
+// readProcStat 读取 /proc/<pid>/stat 中 comm 之后的字段
+// /proc/<pid>/stat: 6246 (sleep) S 1 ...，comm 中可能包含空格，从最后一个 ')' 之后开始切分
+func readProcStat(pid int) ([]string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	stat := string(content)
+	return strings.Fields(stat[strings.LastIndex(stat, ")")+1:]), nil
+}
+
+// IsProcessAlive 判断进程是否存活，僵尸进程也视为已经退出
+func IsProcessAlive(pid int) bool {
+	fields, err := readProcStat(pid)
+	if err != nil {
+		return false
+	}
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// GetProcessStartTime 获取进程的启动时间（系统启动后的 clock ticks），用来判断 pid 是否被其他进程复用
+func GetProcessStartTime(pid int) (uint64, error) {
+	fields, err := readProcStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) <= startTimeIndex {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	return strconv.ParseUint(fields[startTimeIndex], 10, 64)
+}
+
+// IsSameProcess 判断 pid 对应的进程是否存活，并且就是启动时间为 startTime 的那个进程
+// startTime 为 0 时表示没有记录启动时间，只判断进程是否存活
+func IsSameProcess(pid int, startTime uint64) bool {
+	if !IsProcessAlive(pid) {
+		return false
+	}
+	if startTime == 0 {
+		return true
+	}
+	current, err := GetProcessStartTime(pid)
+	return err == nil && current == startTime
+}
+
+// GetProcessStatus 读取 /proc/<pid>/status 中的进程信息
+func GetProcessStatus(pid int) (*ProcessStatus, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessStatus(content)
+}
+
+// parseProcessStatus 解析 status 文件，每行为 "Key:\tvalue"，比如 NSpid:\t6246\t1
+func parseProcessStatus(content []byte) (*ProcessStatus, error) {
+	status := &ProcessStatus{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch key {
+		case "Name":
+			status.Name = fields[0]
+		case "Pid":
+			status.Pid, err = strconv.Atoi(fields[0])
+		case "Tgid":
+			status.Tgid, err = strconv.Atoi(fields[0])
+		case "PPid":
+			status.PPid, err = strconv.Atoi(fields[0])
+		case "NSpid":
+			// 从外到内依次为各层 pid namespace 中的 pid
+			status.NSPid, err = strconv.Atoi(fields[len(fields)-1])
+		case "Uid":
+			// 依次为 real、effective、saved、filesystem uid
+			status.Uid, err = strconv.Atoi(fields[0])
+		case "VmRSS":
+			var kb int64
+			kb, err = strconv.ParseInt(fields[0], 10, 64)
+			status.RSS = kb * 1024
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in process status: %w", key, err)
+		}
+	}
+	if status.Pid == 0 {
+		return nil, fmt.Errorf("invalid process status, missing pid")
+	}
+	// 老版本内核没有 NSpid 字段
+	if status.NSPid == 0 {
+		status.NSPid = status.Pid
+	}
+	return status, scanner.Err()
+}
+
+// GetProcessCPUTime 获取进程在用户态和内核态消耗的 CPU 时间之和
+func GetProcessCPUTime(pid int) (time.Duration, error) {
+	fields, err := readProcStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) <= stimeIndex {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	var ticks uint64
+	for _, index := range []int{utimeIndex, stimeIndex} {
+		value, err := strconv.ParseUint(fields[index], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid stat of process %d: %w", pid, err)
+		}
+		ticks += value
+	}
+	return time.Duration(ticks) * time.Second / clockTicks, nil
+}
+
+// GetProcessCmdline 获取进程的命令行，参数之间以空格分隔，内核线程和僵尸进程的命令行为空
+func GetProcessCmdline(pid int) (string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes.ReplaceAll(content, []byte{0}, []byte{' '}))), nil
+}
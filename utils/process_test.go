@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSameProcess(t *testing.T) {
+	pid := os.Getpid()
+	startTime, err := GetProcessStartTime(pid)
+	assert.Nil(t, err)
+	assert.True(t, IsSameProcess(pid, startTime))
+	assert.True(t, IsSameProcess(pid, 0))
+	assert.False(t, IsSameProcess(pid, startTime+1))
+	assert.False(t, IsProcessAlive(-1))
+}
+
+func TestParseProcessStatus(t *testing.T) {
+	content := "Name:\tsleep\nUmask:\t0022\nState:\tS (sleeping)\nTgid:\t6246\nNgid:\t0\nPid:\t6246\nPPid:\t6240\n" +
+		"Uid:\t1000\t1000\t1000\t1000\nNSpid:\t6246\t3\nVmRSS:\t    1024 kB\n"
+	status, err := parseProcessStatus([]byte(content))
+	assert.Nil(t, err)
+	assert.Equal(t, &ProcessStatus{Name: "sleep", Pid: 6246, Tgid: 6246, PPid: 6240, NSPid: 3, Uid: 1000, RSS: 1024 * 1024}, status)
+
+	// 内核线程没有 VmRSS，老版本内核没有 NSpid
+	status, err = parseProcessStatus([]byte("Name:\tkthreadd\nPid:\t2\nPPid:\t0\nUid:\t0\t0\t0\t0\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, status.NSPid)
+	assert.Equal(t, int64(0), status.RSS)
+
+	_, err = parseProcessStatus([]byte("Name:\tsleep\n"))
+	assert.NotNil(t, err)
+}
+
+func TestGetProcessInfo(t *testing.T) {
+	pid := os.Getpid()
+	status, err := GetProcessStatus(pid)
+	assert.Nil(t, err)
+	assert.Equal(t, pid, status.Pid)
+	assert.Equal(t, os.Getuid(), status.Uid)
+	assert.True(t, status.RSS > 0)
+
+	cpuTime, err := GetProcessCPUTime(pid)
+	assert.Nil(t, err)
+	assert.True(t, cpuTime >= time.Duration(0))
+
+	cmdline, err := GetProcessCmdline(pid)
+	assert.Nil(t, err)
+	assert.Contains(t, cmdline, os.Args[0])
+}
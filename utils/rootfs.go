@@ -23,6 +23,11 @@ func GetImagePath(imageName string) string {
 	return path.Join(ImagePath, fmt.Sprintf("%s.tar", imageName))
 }
 
+// GetImageConfigPath 镜像元数据和镜像 tar 包放在同一目录
+func GetImageConfigPath(imageName string) string {
+	return path.Join(ImagePath, fmt.Sprintf("%s.json", imageName))
+}
+
 func GetLowerPath(containerId string) string {
 	return fmt.Sprintf(lowerPathFormat, containerId)
 }
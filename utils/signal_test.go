@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSignal(t *testing.T) {
+	for _, s := range []string{"SIGTERM", "TERM", "term", "15"} {
+		sig, err := ParseSignal(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, syscall.SIGTERM, sig, s)
+	}
+
+	for _, s := range []string{"", "SIGFOO", "0", "65"} {
+		_, err := ParseSignal(s)
+		assert.NotNil(t, err, s)
+	}
+}
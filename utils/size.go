@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+
+// binaryUnits ParseSize 支持的单位，和内核解析 memory.limit_in_bytes 一样使用 1024 进制
+var binaryUnits = map[byte]int64{
+	'k': 1 << 10,
+	'm': 1 << 20,
+	'g': 1 << 30,
+	't': 1 << 40,
+}
+
+// ParseSize 解析 --mem 这样的大小参数，比如 "1024"、"100m"、"1G"，单位可以带上 b，比如 "100mb"
+func ParseSize(size string) (int64, error) {
+	value := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(size)), "b")
+	multiplier := int64(1)
+	if n := len(value); n > 0 {
+		if unit, ok := binaryUnits[value[n-1]]; ok {
+			multiplier = unit
+			value = value[:n-1]
+		}
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	return number * multiplier, nil
+}
+
+// HumanSize 将字节数转换为易读的字符串，和 docker 一样使用 1000 进制，比如 "1.5MB"
+func HumanSize(size int64) string {
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(sizeUnits)-1 {
+		value /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.4g%s", value, sizeUnits[unit])
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"100b":  100,
+		"4k":    4 << 10,
+		"100m":  100 << 20,
+		"100MB": 100 << 20,
+		"1G":    1 << 30,
+	}
+	for size, want := range cases {
+		got, err := ParseSize(size)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, size)
+	}
+	for _, size := range []string{"", "m", "-1", "1.5g", "100x"} {
+		_, err := ParseSize(size)
+		assert.NotNil(t, err, size)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	cases := map[int64]string{
+		0:             "0B",
+		999:           "999B",
+		1000:          "1kB",
+		1500000:       "1.5MB",
+		1234567890:    "1.235GB",
+		3000000000000: "3TB",
+	}
+	for size, want := range cases {
+		assert.Equal(t, want, HumanSize(size))
+	}
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// IsTerminal 通过 TCGETS 判断 f 是否为终端，/dev/null 这样的字符设备不算终端
+func IsTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                        "Less than a second",
+		time.Second:              "1 second",
+		30 * time.Second:         "30 seconds",
+		time.Minute:              "About a minute",
+		5 * time.Minute:          "5 minutes",
+		time.Hour:                "About an hour",
+		3 * time.Hour:            "3 hours",
+		3 * 24 * time.Hour:       "3 days",
+		21 * 24 * time.Hour:      "3 weeks",
+		90 * 24 * time.Hour:      "3 months",
+		3 * 365 * 24 * time.Hour: "3 years",
+	}
+	for d, want := range cases {
+		assert.Equal(t, want, HumanDuration(d), d.String())
+	}
+}